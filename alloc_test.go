@@ -0,0 +1,42 @@
+package olog
+
+import (
+	"context"
+	"testing"
+)
+
+// TestAllocs enforces the allocation budgets of the common logging calls. The calls through the Logger
+// interface which are not devirtualized allocate the variadic arguments at the call site, so the ctxLogger
+// has a budget of one allocation.
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+
+	for _, encode := range []EncodeType{JSON, PLAIN} {
+		l := NewLogger(WithLoggerWriter(NewWriter(discard{})), WithLoggerCaller(false), WithLoggerEncode(encode)).(*logger)
+		lc := NewLogger(WithLoggerWriter(NewWriter(discard{})), WithLoggerEncode(encode)).(*logger)
+		ctx := WithContext(l, context.Background())
+
+		tests := []struct {
+			name   string
+			budget float64
+			f      func()
+		}{
+			{name: "Info", f: func() { l.Info("test message", "name", "bob", "age", 18) }},
+			{name: "Infof", f: func() { l.Infof("test message name %s age %d success %t", "bob", 18, true) }},
+			{name: "Infow", f: func() {
+				l.Infow("test message", Field{Key: "name", Value: "bob"}, Field{Key: "age", Value: 18}, Field{Key: "name", Value: "alice"})
+			}},
+			{name: "caller.Infow", f: func() { lc.Infow("test message", Field{Key: "name", Value: "bob"}) }},
+			{name: "ctx.Info", budget: 1, f: func() { ctx.Info("test message") }},
+			{name: "ctx.Infow", budget: 1, f: func() { ctx.Infow("test message", Field{Key: "name", Value: "bob"}) }},
+		}
+
+		for _, tt := range tests {
+			if allocs := testing.AllocsPerRun(100, tt.f); allocs > tt.budget {
+				t.Errorf("encode %d %s: allocs = %v, want <= %v", encode, tt.name, allocs, tt.budget)
+			}
+		}
+	}
+}
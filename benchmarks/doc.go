@@ -0,0 +1,5 @@
+// Package benchmarks compares the performance of olog with zap, zerolog and slog, it is a separate module so
+// that olog does not depend on the other loggers.
+//
+//	cd benchmarks && go test -bench . -benchmem
+package benchmarks
@@ -0,0 +1,18 @@
+module github.com/welllog/olog/benchmarks
+
+go 1.23
+
+replace github.com/welllog/olog => ../
+
+require (
+	github.com/rs/zerolog v1.35.1
+	github.com/welllog/olog v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.28.0
+)
+
+require (
+	github.com/mattn/go-colorable v0.1.14 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
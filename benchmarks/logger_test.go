@@ -0,0 +1,216 @@
+package benchmarks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/welllog/olog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const msg = "test message"
+
+func newOlog() olog.Logger {
+	return olog.NewLogger(olog.WithLoggerWriter(olog.NewWriter(io.Discard)), olog.WithLoggerCaller(false))
+}
+
+func newZap() *zap.Logger {
+	enc := zap.NewProductionEncoderConfig()
+	enc.EncodeTime = zapcore.ISO8601TimeEncoder
+	return zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(enc), zapcore.AddSync(io.Discard), zapcore.DebugLevel))
+}
+
+func newZerolog() zerolog.Logger {
+	return zerolog.New(io.Discard).With().Timestamp().Logger()
+}
+
+func newSlog() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(io.Discard, nil))
+}
+
+func BenchmarkMessage(b *testing.B) {
+	b.Run("olog", func(b *testing.B) {
+		logger := newOlog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+
+	b.Run("zap", func(b *testing.B) {
+		logger := newZap()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+
+	b.Run("zerolog", func(b *testing.B) {
+		logger := newZerolog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info().Msg(msg)
+			}
+		})
+	})
+
+	b.Run("slog", func(b *testing.B) {
+		logger := newSlog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+}
+
+func BenchmarkFormat(b *testing.B) {
+	b.Run("olog", func(b *testing.B) {
+		logger := newOlog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Infof("user %s logged in %d times", "bob", 18)
+			}
+		})
+	})
+
+	b.Run("zap", func(b *testing.B) {
+		logger := newZap().Sugar()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Infof("user %s logged in %d times", "bob", 18)
+			}
+		})
+	})
+
+	b.Run("zerolog", func(b *testing.B) {
+		logger := newZerolog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info().Msgf("user %s logged in %d times", "bob", 18)
+			}
+		})
+	})
+}
+
+func BenchmarkFields(b *testing.B) {
+	b.Run("olog", func(b *testing.B) {
+		logger := newOlog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Infow(msg,
+					olog.Field{Key: "name", Value: "bob"},
+					olog.Field{Key: "age", Value: 18},
+					olog.Field{Key: "success", Value: true},
+					olog.Field{Key: "elapsed", Value: time.Second},
+				)
+			}
+		})
+	})
+
+	b.Run("zap", func(b *testing.B) {
+		logger := newZap()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg,
+					zap.String("name", "bob"),
+					zap.Int("age", 18),
+					zap.Bool("success", true),
+					zap.Duration("elapsed", time.Second),
+				)
+			}
+		})
+	})
+
+	b.Run("zerolog", func(b *testing.B) {
+		logger := newZerolog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info().
+					Str("name", "bob").
+					Int("age", 18).
+					Bool("success", true).
+					Dur("elapsed", time.Second).
+					Msg(msg)
+			}
+		})
+	})
+
+	b.Run("slog", func(b *testing.B) {
+		logger := newSlog()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.LogAttrs(context.Background(), slog.LevelInfo, msg,
+					slog.String("name", "bob"),
+					slog.Int("age", 18),
+					slog.Bool("success", true),
+					slog.Duration("elapsed", time.Second),
+				)
+			}
+		})
+	})
+}
+
+func BenchmarkContextFields(b *testing.B) {
+	b.Run("olog", func(b *testing.B) {
+		logger := olog.WithFields(newOlog(),
+			olog.Field{Key: "name", Value: "bob"},
+			olog.Field{Key: "age", Value: 18},
+			olog.Field{Key: "success", Value: true},
+		)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+
+	b.Run("zap", func(b *testing.B) {
+		logger := newZap().With(zap.String("name", "bob"), zap.Int("age", 18), zap.Bool("success", true))
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+
+	b.Run("zerolog", func(b *testing.B) {
+		logger := newZerolog().With().Str("name", "bob").Int("age", 18).Bool("success", true).Logger()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info().Msg(msg)
+			}
+		})
+	})
+
+	b.Run("slog", func(b *testing.B) {
+		logger := newSlog().With(slog.String("name", "bob"), slog.Int("age", 18), slog.Bool("success", true))
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info(msg)
+			}
+		})
+	})
+}
@@ -0,0 +1,274 @@
+package olog
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallerPath is the form of the file of the caller.
+type CallerPath int8
+
+const (
+	// CallerShortPath is the last two segments of the file, such as "olog/logger.go".
+	CallerShortPath CallerPath = iota
+	// CallerFullPath is the full path of the file.
+	CallerFullPath
+	// CallerModulePath is the path of the file relative to the main module, such as "internal/db/pool.go",
+	// the files of the other modules are written with their import paths, such as "github.com/x/y/z.go".
+	// The root of the main module is found by its go.mod for the files of the main packages, such as
+	// "cmd/api/main.go", they are written as the last two segments if it is not found.
+	CallerModulePath
+	// CallerSegments is the last CallerOptions.Segments segments of the file.
+	CallerSegments
+)
+
+// CallerOptions configures the caller written by the encoding, the ShortFile of the logger is ignored
+// if they are set.
+type CallerOptions struct {
+	Path     CallerPath // Path is the form of the file
+	Segments int        // Segments is the number of the last segments of the file for CallerSegments
+	Function bool       // Function writes the function of the caller as "pkg.Func" after the file and line
+	Split    bool       // Split writes the caller as the separate "file", "line" and "func" keys on JSON encoding
+}
+
+// mainModule is the path of the main module read from the build info, such as "github.com/x/y".
+var mainModule = func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path
+	}
+	return ""
+}()
+
+// CallerFile returns the file of the caller frame in the form configured by the record.
+func (r Record) CallerFile(frame runtime.Frame) string {
+	o := r.CallerOpts
+	if o == nil {
+		if r.ShortFile.IsOpen() {
+			return shortFile(frame.File)
+		}
+		return frame.File
+	}
+
+	switch o.Path {
+	case CallerFullPath:
+		return frame.File
+	case CallerModulePath:
+		return moduleFile(frame.Function, frame.File)
+	case CallerSegments:
+		return lastSegments(frame.File, o.Segments)
+	default:
+		return shortFile(frame.File)
+	}
+}
+
+// callerFunction reports whether to write the function of the caller.
+func (r Record) callerFunction() bool {
+	return r.CallerOpts != nil && r.CallerOpts.Function
+}
+
+// callerSplit reports whether to write the caller as the separate keys on JSON encoding.
+func (r Record) callerSplit() bool {
+	return r.CallerOpts != nil && r.CallerOpts.Split
+}
+
+// funcName returns the function without the import path of its package, such as "olog.(*logger).Info".
+func funcName(function string) string {
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+// funcPackage returns the import path of the package of the function, such as "github.com/welllog/olog".
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if i := strings.IndexByte(function[slash+1:], '.'); i >= 0 {
+		return function[:slash+1+i]
+	}
+	return function
+}
+
+// moduleFile returns the file of the function as the import path of its package followed by the base name,
+// with the path of the main module trimmed.
+func moduleFile(function, file string) string {
+	dir, base := "", file
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		dir, base = file[:i], file[i+1:]
+	}
+
+	pkg := funcPackage(function)
+	switch {
+	case pkg == "main":
+		return mainFile(dir, file)
+	case pkg == "" || pkg == mainModule:
+		return base
+	case mainModule != "" && strings.HasPrefix(pkg, mainModule+"/"):
+		return pkg[len(mainModule)+1:] + "/" + base
+	default:
+		return pkg + "/" + base
+	}
+}
+
+// mainFile returns the file of a main package relative to the root of the main module. The files are prefixed
+// by the path of the main module if the program is built with -trimpath, otherwise the root is the nearest
+// directory of the file with a go.mod, and the last two segments of the file are returned if there is none.
+func mainFile(dir, file string) string {
+	if mainModule != "" && strings.HasPrefix(file, mainModule+"/") {
+		return file[len(mainModule)+1:]
+	}
+	if root := callers.moduleRoot(dir); root != "" {
+		return file[len(root)+1:]
+	}
+	return lastSegments(file, 2)
+}
+
+// findModuleRoot returns the nearest directory of the dir or its parents with a go.mod, or "" if there is none.
+func findModuleRoot(dir string) string {
+	for dir != "" {
+		if _, err := os.Stat(dir + "/go.mod"); err == nil {
+			return dir
+		}
+		i := strings.LastIndexByte(dir, '/')
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+	}
+	return ""
+}
+
+// lastSegments returns the last n segments of the file, or the file if it has fewer segments.
+func lastSegments(file string, n int) string {
+	if n <= 0 {
+		return file
+	}
+	for i := len(file) - 1; i >= 0; i-- {
+		if file[i] == '/' {
+			n--
+			if n == 0 {
+				return file[i+1:]
+			}
+		}
+	}
+	return file
+}
+
+// callerCacheSize is the maximum number of the entries of each map of the caller cache, the map is cleared
+// when it is full.
+const callerCacheSize = 4096
+
+// callerKey is the key of the formatted caller, the caller of the same frame is formatted differently by
+// the options.
+type callerKey struct {
+	pc        uintptr
+	shortFile bool
+	opts      CallerOptions
+}
+
+// callerCache caches the frames resolved from the program counters and the formatted callers, so that the
+// callers of the same call sites are resolved and formatted once. The zero value is ready to use.
+type callerCache struct {
+	mu      sync.RWMutex
+	frames  map[uintptr]runtime.Frame
+	callers map[callerKey]string
+	roots   map[string]string // the module roots of the directories of the main packages
+}
+
+var callers callerCache
+
+// frame returns the frame of the program counter returned by runtime.Callers.
+func (c *callerCache) frame(pc uintptr) runtime.Frame {
+	c.mu.RLock()
+	frame, ok := c.frames[pc]
+	c.mu.RUnlock()
+	if ok {
+		return frame
+	}
+
+	frame, _ = runtime.CallersFrames([]uintptr{pc}).Next()
+	c.mu.Lock()
+	if c.frames == nil || len(c.frames) >= callerCacheSize {
+		c.frames = make(map[uintptr]runtime.Frame)
+	}
+	c.frames[pc] = frame
+	c.mu.Unlock()
+	return frame
+}
+
+// moduleRoot returns the root of the module of the directory found by findModuleRoot.
+func (c *callerCache) moduleRoot(dir string) string {
+	c.mu.RLock()
+	root, ok := c.roots[dir]
+	c.mu.RUnlock()
+	if ok {
+		return root
+	}
+
+	root = findModuleRoot(dir)
+	c.mu.Lock()
+	if c.roots == nil || len(c.roots) >= callerCacheSize {
+		c.roots = make(map[string]string)
+	}
+	c.roots[dir] = root
+	c.mu.Unlock()
+	return root
+}
+
+// caller returns the caller formatted by format for the key.
+func (c *callerCache) caller(key callerKey, format func() string) string {
+	c.mu.RLock()
+	s, ok := c.callers[key]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = format()
+	c.mu.Lock()
+	if c.callers == nil || len(c.callers) >= callerCacheSize {
+		c.callers = make(map[callerKey]string)
+	}
+	c.callers[key] = s
+	c.mu.Unlock()
+	return s
+}
+
+// FirstFrame returns the first frame of the record, and the frames after it if the stack is enabled, it must
+// be called by the EncodeFunc itself, at the same depth as Frames. The caller is resolved by the cache without
+// allocations if the stack is disabled.
+func (r Record) FirstFrame() (frame runtime.Frame, frames *runtime.Frames, more bool) {
+	if r.Stack.IsOpen() && r.StackSize > 0 {
+		pc := make([]uintptr, r.StackSize)
+		n := runtime.Callers(int(r.CallerSkip+1), pc)
+		frames = runtime.CallersFrames(pc[:n])
+		frame, more = frames.Next()
+		return frame, frames, more
+	}
+
+	if r.Caller.IsOpen() {
+		var pc [1]uintptr
+		if runtime.Callers(int(r.CallerSkip+1), pc[:]) > 0 {
+			frame = callers.frame(pc[0])
+		}
+	}
+	return frame, nil, false
+}
+
+// caller returns the caller of the frame as "file:line", followed by the function if it is enabled.
+func (r Record) caller(frame runtime.Frame) string {
+	key := callerKey{pc: frame.PC, shortFile: r.ShortFile.IsOpen()}
+	if r.CallerOpts != nil {
+		key.opts = *r.CallerOpts
+	}
+	return callers.caller(key, func() string {
+		s := r.CallerFile(frame) + ":" + strconv.Itoa(frame.Line)
+		if r.callerFunction() {
+			s += " " + funcName(frame.Function)
+		}
+		return s
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -34,7 +36,7 @@ func TestLogCaller(t *testing.T) {
 	Tracef("hello %s", "world")
 	Tracew("hello")
 
-	err := validCaller(buf, "olog/caller_test.go", 17)
+	err := validCaller(buf, "olog/caller_test.go", 19)
 	if err != nil {
 		t.Error(err)
 	}
@@ -64,7 +66,7 @@ func TestLoggerCaller(t *testing.T) {
 	logger.Tracef("hello %s", "world")
 	logger.Tracew("hello")
 
-	err := validCaller(buf, "olog/caller_test.go", 47)
+	err := validCaller(buf, "olog/caller_test.go", 49)
 	if err != nil {
 		t.Error(err)
 	}
@@ -96,7 +98,7 @@ func TestContextLoggerCaller(t *testing.T) {
 	logger.Tracef("hello %s", "world")
 	logger.Tracew("hello")
 
-	err := validCaller(buf, "olog/caller_test.go", 79)
+	err := validCaller(buf, "olog/caller_test.go", 81)
 	if err != nil {
 		t.Error(err)
 	}
@@ -120,3 +122,117 @@ func validCaller(buf *bytes.Buffer, file string, startLine int) error {
 	}
 	return nil
 }
+
+func TestCallerOptions(t *testing.T) {
+	tests := []struct {
+		opts *CallerOptions
+		want string
+	}{
+		{&CallerOptions{}, `"caller":"olog/caller_test.go:139"`},
+		{&CallerOptions{Path: CallerSegments, Segments: 1, Function: true}, `"caller":"caller_test.go:139 olog.TestCallerOptions"`},
+		{&CallerOptions{Path: CallerModulePath}, `"caller":"caller_test.go:139"`},
+		{&CallerOptions{Path: CallerSegments, Segments: 1, Split: true}, `"file":"caller_test.go","line":139,"func":"olog.TestCallerOptions"`},
+	}
+	for _, tt := range tests {
+		buf := bytes.NewBuffer(nil)
+		logger := NewLogger(WithLoggerWriter(NewWriter(buf)), WithLoggerCallerOptions(tt.opts))
+		logger.Info("hello")
+		if !bytes.Contains(buf.Bytes(), []byte(tt.want)) {
+			t.Errorf("output = %s, want %s", buf.Bytes(), tt.want)
+		}
+	}
+}
+
+func TestModuleFile(t *testing.T) {
+	old := mainModule
+	defer func() { mainModule = old }()
+	mainModule = "github.com/x/app"
+
+	root := filepath.ToSlash(t.TempDir())
+	if err := os.WriteFile(root+"/go.mod", []byte("module github.com/x/app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		function, file, want string
+	}{
+		{"main.main", root + "/cmd/a/main.go", "cmd/a/main.go"},
+		{"main.main", root + "/cmd/b/main.go", "cmd/b/main.go"},
+		{"main.main", root + "/main.go", "main.go"},
+		{"main.main", "github.com/x/app/cmd/c/main.go", "cmd/c/main.go"},
+		{"main.main", "/nomod/cmd/d/main.go", "d/main.go"},
+		{"github.com/x/app.Run", "/src/app/run.go", "run.go"},
+		{"github.com/x/app/internal/db.(*Pool).Get", "/src/app/internal/db/pool.go", "internal/db/pool.go"},
+		{"github.com/y/lib.Do[...]", "/go/pkg/mod/github.com/y/lib@v1.0.0/do.go", "github.com/y/lib/do.go"},
+	}
+	for _, tt := range tests {
+		if got := moduleFile(tt.function, tt.file); got != tt.want {
+			t.Errorf("moduleFile(%s) = %s, want %s", tt.function, got, tt.want)
+		}
+	}
+
+	if got := lastSegments("/a/b/c.go", 2); got != "b/c.go" {
+		t.Errorf("lastSegments = %s, want b/c.go", got)
+	}
+}
+
+func TestCallerCache(t *testing.T) {
+	var c callerCache
+	for i := 0; i < callerCacheSize+10; i++ {
+		c.caller(callerKey{pc: uintptr(i)}, func() string { return "f.go:1" })
+	}
+	if n := len(c.callers); n > callerCacheSize {
+		t.Errorf("cached %d callers, want at most %d", n, callerCacheSize)
+	}
+
+	var calls int
+	format := func() string {
+		calls++
+		return "f.go:1"
+	}
+	c.caller(callerKey{pc: 1 << 20}, format)
+	if s := c.caller(callerKey{pc: 1 << 20}, format); s != "f.go:1" || calls != 1 {
+		t.Errorf("caller = %s formatted %d times, want once", s, calls)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = Record{Caller: Enable, CallerSkip: 1}.FirstFrame()
+	}); allocs != 0 {
+		t.Errorf("FirstFrame allocates %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkCaller(b *testing.B) {
+	r := Record{Caller: Enable, ShortFile: Enable, CallerSkip: 1}
+
+	b.Run("frames", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			frame, _ := r.Frames().Next()
+			_ = shortFile(frame.File)
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			frame, _, _ := r.FirstFrame()
+			_ = r.caller(frame)
+		}
+	})
+}
+
+func BenchmarkCallerInfo(b *testing.B) {
+	for _, enc := range []EncodeType{JSON, PLAIN} {
+		logger := NewLogger(WithLoggerWriter(NewWriter(discard{})), WithLoggerEncode(enc))
+
+		b.Run(map[EncodeType]string{JSON: "json", PLAIN: "plain"}[enc], func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					logger.Infow("test message", Field{Key: "name", Value: "bob"}, Field{Key: "age", Value: 18})
+				}
+			})
+		})
+	}
+}
@@ -1,9 +1,12 @@
-//go:build !windows
-
 package olog
 
+import "strconv"
+
 const (
 	Reset     = "\033[0m"
+	Bold      = "\033[1m"
+	Faint     = "\033[2m"
+	Underline = "\033[4m"
 	Red       = "\033[31m"
 	RedBold   = "\033[1;31m"
 	Green     = "\033[32m"
@@ -17,33 +20,111 @@ const (
 	White     = "\033[97m"
 )
 
+// Color256 returns the ANSI escape sequence of the foreground color n of the 256 color palette.
+func Color256(n uint8) string {
+	return "\033[38;5;" + strconv.Itoa(int(n)) + "m"
+}
+
+// TrueColor returns the ANSI escape sequence of the 24-bit foreground color.
+func TrueColor(r, g, b uint8) string {
+	return "\033[38;2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b)) + "m"
+}
+
+// Theme is the colors of the elements of the plain and console encoding. The colors are ANSI escape
+// sequences, such as the color constants, Color256 and TrueColor, which can be concatenated to combine
+// the styles, such as Bold + Red. An element with an empty color is written unchanged.
+type Theme struct {
+	Levels  map[Level]string // Levels is the colors of the levels, overriding the colors registered by RegisterLevel
+	Level   string           // Level is the color of the levels not in Levels, the registered color is used if it is empty
+	Time    string           // Time is the color of the time
+	App     string           // App is the color of the app name
+	Caller  string           // Caller is the color of the caller and the files of the stack
+	Message string           // Message is the color of the message
+	Key     string           // Key is the color of the field keys
+	Value   string           // Value is the color of the field values written on the line of the message
+	Error   string           // Error is the color of the keys of the multi-line error fields on console encoding
+	Stack   string           // Stack is the color of the stack header on console encoding
+}
+
+var (
+	// ThemeDefault is the theme of the console encoding by default, the levels use the registered colors.
+	ThemeDefault = &Theme{
+		Time:   Gray,
+		App:    Purple,
+		Caller: Blue,
+		Key:    Cyan,
+		Value:  Green,
+		Error:  Red,
+		Stack:  Yellow,
+	}
+
+	// ThemeSolarized is a theme of the solarized palette in truecolor.
+	ThemeSolarized = &Theme{
+		Levels: map[Level]string{
+			TRACE:  TrueColor(0x58, 0x6e, 0x75),
+			DEBUG:  TrueColor(0x6c, 0x71, 0xc4),
+			INFO:   TrueColor(0x85, 0x99, 0x00),
+			NOTICE: TrueColor(0x2a, 0xa1, 0x98),
+			WARN:   TrueColor(0xb5, 0x89, 0x00),
+			ERROR:  TrueColor(0xcb, 0x4b, 0x16),
+			FATAL:  Bold + TrueColor(0xdc, 0x32, 0x2f),
+		},
+		Time:   TrueColor(0x58, 0x6e, 0x75),
+		App:    TrueColor(0xd3, 0x36, 0x82),
+		Caller: TrueColor(0x26, 0x8b, 0xd2),
+		Key:    TrueColor(0x2a, 0xa1, 0x98),
+		Value:  TrueColor(0x93, 0xa1, 0xa1),
+		Error:  TrueColor(0xdc, 0x32, 0x2f),
+		Stack:  TrueColor(0xb5, 0x89, 0x00),
+	}
+
+	// ThemeMonochrome is a theme without colors, which emphasizes the levels and keys in bold.
+	ThemeMonochrome = &Theme{
+		Level:  Bold,
+		Time:   Faint,
+		App:    Bold,
+		Caller: Faint,
+		Key:    Bold,
+		Error:  Bold + Underline,
+		Stack:  Bold,
+	}
+
+	// plainTheme is the theme of the plain encoding by default, which only colors the levels.
+	plainTheme = &Theme{}
+
+	// noTheme is the theme writing all the elements unchanged.
+	noTheme = &Theme{}
+)
+
+// levelColor returns the color of the level, or an empty string if the theme is nil.
+func (t *Theme) levelColor(level Level) string {
+	if t == nil || t == noTheme {
+		return ""
+	}
+	if c, ok := t.Levels[level]; ok {
+		return c
+	}
+	if t.Level != "" {
+		return t.Level
+	}
+	if desc := getLevelRegistry().descs[level]; desc != nil {
+		return desc.Color
+	}
+	return ""
+}
+
 type stringWriter interface {
 	WriteString(string) (int, error)
 }
 
-// writeLevelWithColor takes in a level of logging and a tag string, and returns a string that
-// contains the tag string wrapped with an ANSI color code to represent the level of logging.
-// The returned string will have different colors depending on the level of logging.
-func writeLevelWithColor(level Level, tag string, w stringWriter) {
-	switch level {
-	case FATAL:
-		_, _ = w.WriteString(RedBold)
-	case ERROR:
-		_, _ = w.WriteString(Red)
-	case WARN:
-		_, _ = w.WriteString(Yellow)
-	case NOTICE:
-		_, _ = w.WriteString(Blue)
-	case INFO:
-		_, _ = w.WriteString(Green)
-	case DEBUG:
-		_, _ = w.WriteString(Gray)
-	case TRACE:
-		_, _ = w.WriteString(Cyan)
-	default:
-		_, _ = w.WriteString(tag)
+// writeColored writes the string wrapped with the color and Reset, the string is written unchanged
+// if the color or the string is empty.
+func writeColored(w stringWriter, color, s string) {
+	if color == "" || s == "" {
+		_, _ = w.WriteString(s)
 		return
 	}
-	_, _ = w.WriteString(tag)
+	_, _ = w.WriteString(color)
+	_, _ = w.WriteString(s)
 	_, _ = w.WriteString(Reset)
 }
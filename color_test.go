@@ -0,0 +1,107 @@
+package olog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestColorSequences(t *testing.T) {
+	if got := Color256(208); got != "\033[38;5;208m" {
+		t.Errorf("Color256(208) = %q", got)
+	}
+	if got := TrueColor(0x26, 0x8b, 0xd2); got != "\033[38;2;38;139;210m" {
+		t.Errorf("TrueColor(0x26, 0x8b, 0xd2) = %q", got)
+	}
+}
+
+func TestThemeLevelColor(t *testing.T) {
+	theme := &Theme{Levels: map[Level]string{INFO: Color256(2)}}
+	if got := theme.levelColor(INFO); got != Color256(2) {
+		t.Errorf("levelColor(INFO) = %q, want %q", got, Color256(2))
+	}
+	if got := theme.levelColor(ERROR); got != Red {
+		t.Errorf("levelColor(ERROR) = %q, want the registered %q", got, Red)
+	}
+
+	theme.Level = Bold
+	if got := theme.levelColor(ERROR); got != Bold {
+		t.Errorf("levelColor(ERROR) = %q, want %q", got, Bold)
+	}
+
+	if got := noTheme.levelColor(ERROR); got != "" {
+		t.Errorf("noTheme.levelColor(ERROR) = %q, want empty", got)
+	}
+	if got := (*Theme)(nil).levelColor(ERROR); got != "" {
+		t.Errorf("nil.levelColor(ERROR) = %q, want empty", got)
+	}
+}
+
+func TestLoggerTheme(t *testing.T) {
+	if !colorSupported() {
+		t.Skip("colors are not supported")
+	}
+
+	theme := &Theme{
+		Levels:  map[Level]string{WARN: TrueColor(1, 2, 3)},
+		Time:    Faint,
+		App:     Bold,
+		Caller:  Color256(4),
+		Message: Underline,
+		Key:     Color256(5),
+		Value:   Color256(6),
+	}
+
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerColor(true),
+		WithLoggerTheme(theme), WithLoggerAppName("svc"), WithLoggerTimeFormat("2006"))
+	logger.Warnw("hello", Field{Key: "user", Value: "bob"})
+
+	year := strings.SplitN(buf.String(), "\t", 2)[0]
+	want := year + "\t" + TrueColor(1, 2, 3) + "warn" + Reset + "\t" + Bold + "svc" + Reset + "\t" +
+		Color256(4) + "olog/color_test.go:58" + Reset + "\t" + Underline + "hello" + Reset + "\t" +
+		Color256(5) + "user" + Reset + "=" + Color256(6) + "bob" + Reset + "\n"
+	if !strings.HasPrefix(year, Faint) || buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	// the plain encoding only colors the levels by default.
+	buf.Reset()
+	logger = NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerColor(true),
+		WithLoggerAppName("svc"), WithLoggerTimeFormat(""))
+	logger.Warnw("hello", Field{Key: "user", Value: "bob"})
+
+	want = "\t" + Yellow + "warn" + Reset + "\tsvc\tolog/color_test.go:72\thello\tuser=bob\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPresetThemes(t *testing.T) {
+	if !colorSupported() {
+		t.Skip("colors are not supported")
+	}
+
+	for name, theme := range map[string]*Theme{
+		"default":    ThemeDefault,
+		"solarized":  ThemeSolarized,
+		"monochrome": ThemeMonochrome,
+	} {
+		var buf bytes.Buffer
+		logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(CONSOLE), WithLoggerColor(true),
+			WithLoggerTheme(theme))
+		logger.Errorw("hello", Field{Key: "user", Value: "bob"})
+
+		out := buf.String()
+		for _, color := range []string{theme.levelColor(ERROR) + "ERROR", theme.Caller + "olog/color_test.go", theme.Key + "user", theme.Value + "bob"} {
+			if !strings.Contains(out, color+Reset) && !strings.Contains(out, color) {
+				t.Errorf("%s: output %q does not contain %q", name, out, color)
+			}
+		}
+	}
+
+	// the monochrome theme does not use the registered colors.
+	if c := ThemeMonochrome.levelColor(ERROR); c != Bold {
+		t.Errorf("monochrome levelColor(ERROR) = %q, want %q", c, Bold)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package olog
+
+// colorSupported reports whether the console supports the ANSI color codes.
+func colorSupported() bool {
+	return true
+}
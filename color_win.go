@@ -2,13 +2,53 @@
 
 package olog
 
-type stringWriter interface {
-	WriteString(string) (int, error)
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag to handle the ANSI escape sequences.
+const enableVirtualTerminalProcessing = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+var (
+	colorOnce sync.Once
+	colorOK   bool
+)
+
+// colorSupported reports whether the Windows console supports the ANSI color codes, which requires
+// the virtual terminal processing available since Windows 10. The virtual terminal processing of the
+// console is enabled on the first call, when the colors are first detected or written.
+func colorSupported() bool {
+	colorOnce.Do(func() {
+		stdout := enableVirtualTerminal(os.Stdout)
+		stderr := enableVirtualTerminal(os.Stderr)
+		colorOK = stdout || stderr
+	})
+	return colorOK
+}
+
+// enableVirtualTerminal enables the virtual terminal processing of the console, and reports whether
+// it is enabled.
+func enableVirtualTerminal(f *os.File) bool {
+	h := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+
+	ok, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	return ok != 0
 }
 
-// writeLevelWithColor is a stub function that always returns the input tag string unchanged. It's used as a placeholder
-// function on Windows OS because the ANSI color codes that are used to format console output on Unix-based systems aren't
-// supported by the Windows console.
-func writeLevelWithColor(level Level, tag string, w stringWriter) {
-	_, _ = w.WriteString(tag)
+// isTerminal reports whether the file is a console, which has the console mode.
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
 }
@@ -0,0 +1,261 @@
+package olog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/welllog/olog/encoder"
+)
+
+const (
+	// consoleLevelWidth is the minimum width of the level column of the console encoding.
+	consoleLevelWidth = 6
+	// consoleCallerWidth is the minimum width of the caller column of the console encoding.
+	consoleCallerWidth = 24
+	// consoleMessageWidth is the minimum width of the message column followed by fields of the console encoding.
+	consoleMessageWidth = 40
+	// consoleIndent is the indentation of the multi-line fields and the stack of the console encoding.
+	consoleIndent = "    "
+)
+
+// consoleStart is the start time of the relative timestamps of the console encoding.
+var consoleStart = time.Now()
+
+// autoColor reports whether the ANSI color codes should be written to the file when the color is not set
+// explicitly. NO_COLOR disables the colors, FORCE_COLOR enables the colors even if the file is not a terminal,
+// otherwise the file must be a terminal supporting colors and TERM is not dumb.
+func autoColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" && force != "false" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return colorSupported() && isTerminal(f)
+}
+
+// writerColor reports whether the ANSI color codes should be written by the writer for the level when
+// the color is not set explicitly, which is detected for each stream of the console writer and the
+// writers of a file.
+func writerColor(w Writer, level Level) bool {
+	switch cw := w.(type) {
+	case *consoleWriter:
+		if isStderrLevel(level) {
+			return cw.ecolor
+		}
+		return cw.scolor
+	case *customWriter:
+		return cw.color
+	default:
+		return false
+	}
+}
+
+// consoleEncode to encode a Record object as a human-friendly line with aligned columns to the buffer
+// for development. The time is relative to the start of the program, the multi-line fields and the stack
+// are indented below the line. The elements are colored by the theme unless it is nil.
+func consoleEncode(r Record, buf *encoder.Buffer, theme *Theme) {
+	enc := encoder.PlainEncoder{Buffer: buf}
+	if theme == nil {
+		theme = noTheme
+	}
+
+	_, _ = enc.WriteString(theme.Time)
+	writeConsoleElapsed(enc, r.Time.Sub(consoleStart))
+	if theme.Time != "" {
+		_, _ = enc.WriteString(Reset)
+	}
+	_ = enc.WriteByte(' ')
+
+	levelColor := theme.levelColor(r.Level)
+	if levelColor != "" {
+		_, _ = enc.WriteString(levelColor)
+	}
+	for i := 0; i < len(r.LevelTag); i++ {
+		c := r.LevelTag[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		_ = enc.WriteByte(c)
+	}
+	if levelColor != "" {
+		_, _ = enc.WriteString(Reset)
+	}
+	writeConsolePadding(enc, consoleLevelWidth-len(r.LevelTag)+1)
+
+	if r.App != "" {
+		writeColored(enc, theme.App, r.App)
+		_ = enc.WriteByte(' ')
+	}
+
+	if r.Name != "" {
+		writeColored(enc, theme.App, r.Name)
+		_ = enc.WriteByte(' ')
+	}
+
+	frame, frames, more := r.FirstFrame()
+
+	if r.Caller.IsOpen() {
+		caller := r.caller(frame)
+		_, _ = enc.WriteString(theme.Caller)
+		_, _ = enc.WriteString(caller)
+		if theme.Caller != "" {
+			_, _ = enc.WriteString(Reset)
+		}
+		writeConsolePadding(enc, consoleCallerWidth-len(caller)+1)
+	}
+
+	_, _ = enc.WriteString(theme.Message)
+	msgStart := enc.Len()
+	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	// the message is only padded if it is a single line.
+	msgWidth := -1
+	if msg := enc.Bytes()[msgStart:]; bytes.IndexByte(msg, '\n') < 0 {
+		msgWidth = utf8.RuneCount(msg)
+	}
+	if theme.Message != "" {
+		_, _ = enc.WriteString(Reset)
+	}
+
+	// the multi-line fields are written after the line of the message.
+	var multiline []consoleField
+	dedup := newFieldDedup(r.Duplicates, r.Fields, filterField)
+	for i, field := range r.Fields {
+		key, ok := dedup.key(i)
+		if !ok {
+			continue
+		}
+		if text := consoleMultiline(field.Value, r.RichError.IsOpen()); text != "" {
+			_, isErr := field.Value.(error)
+			multiline = append(multiline, consoleField{key: key, text: text, err: isErr})
+			continue
+		}
+
+		if msgWidth >= 0 {
+			// pad the message so that the fields of the lines are aligned.
+			writeConsolePadding(enc, consoleMessageWidth-msgWidth)
+			msgWidth = -1
+		}
+		_ = enc.WriteByte(' ')
+		writeColored(enc, theme.Key, key)
+		_ = enc.WriteByte('=')
+		_, _ = enc.WriteString(theme.Value)
+		enc.WriteValue(field.Value)
+		if theme.Value != "" {
+			_, _ = enc.WriteString(Reset)
+		}
+	}
+
+	for _, field := range multiline {
+		_, _ = enc.WriteString("\n" + consoleIndent)
+		if field.err {
+			writeColored(enc, theme.Error, field.key)
+		} else {
+			writeColored(enc, theme.Key, field.key)
+		}
+		_, _ = enc.WriteString(": ")
+		_, _ = enc.WriteString(strings.ReplaceAll(strings.TrimRight(field.text, "\n"), "\n", "\n"+consoleIndent+consoleIndent))
+	}
+
+	if r.Stack.IsOpen() && frame.PC != 0 {
+		opts := r.StackOpts
+		_, _ = enc.WriteString("\n" + consoleIndent)
+		writeColored(enc, theme.Stack, fieldStack)
+		_ = enc.WriteByte(':')
+		if opts != nil && opts.Goroutine {
+			_, _ = enc.WriteString(" goroutine ")
+			enc.WriteUint64(goroutineID())
+		}
+		opts.eachFrame(frame, frames, more, func(function, file string, line int) {
+			_, _ = enc.WriteString("\n" + consoleIndent + consoleIndent)
+			_, _ = enc.WriteString(function)
+			_, _ = enc.WriteString("\n" + consoleIndent + consoleIndent + consoleIndent)
+			_, _ = enc.WriteString(theme.Caller)
+			_, _ = enc.WriteString(file)
+			_ = enc.WriteByte(':')
+			enc.WriteInt64(int64(line))
+			if theme.Caller != "" {
+				_, _ = enc.WriteString(Reset)
+			}
+		})
+	}
+
+	_ = enc.WriteByte('\n')
+}
+
+// consoleField is a multi-line field written after the line of the message.
+type consoleField struct {
+	key  string
+	text string
+	err  bool
+}
+
+// consoleMultiline returns the text of the value if it spans multiple lines, such as an error formatted
+// with a stack by %+v, or an empty string. The error is written with its chain and stack if rich is true.
+func consoleMultiline(value any, rich bool) string {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, "\n") {
+			return v
+		}
+	case error:
+		if rich {
+			buf := encoder.NewBuffer(nil)
+			writePlainError(encoder.PlainEncoder{Buffer: buf}, v, "\n", consoleIndent)
+			if bytes.IndexByte(buf.Bytes(), '\n') >= 0 {
+				return string(buf.Bytes())
+			}
+			return ""
+		}
+		text := v.Error()
+		if f, ok := v.(fmt.Formatter); ok {
+			if verbose := fmt.Sprintf("%+v", f); verbose != text {
+				text = verbose
+			}
+		}
+		if strings.Contains(text, "\n") {
+			return text
+		}
+	}
+	return ""
+}
+
+// writeConsoleElapsed writes the elapsed time as seconds with milliseconds right aligned in 9 columns.
+func writeConsoleElapsed(enc encoder.PlainEncoder, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+
+	secs := ms / 1000
+	width := 1
+	for n := secs; n >= 10; n /= 10 {
+		width++
+	}
+	writeConsolePadding(enc, 4-width)
+	enc.WriteInt64(secs)
+	_ = enc.WriteByte('.')
+	frac := ms % 1000
+	if frac < 100 {
+		_ = enc.WriteByte('0')
+	}
+	if frac < 10 {
+		_ = enc.WriteByte('0')
+	}
+	enc.WriteInt64(frac)
+	_ = enc.WriteByte('s')
+}
+
+// writeConsolePadding writes n spaces.
+func writeConsolePadding(enc encoder.PlainEncoder, n int) {
+	for ; n > 0; n-- {
+		_ = enc.WriteByte(' ')
+	}
+}
@@ -0,0 +1,171 @@
+package olog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// stackError is an error printing a stack with %+v.
+type stackError struct {
+	msg string
+}
+
+func (e stackError) Error() string {
+	return e.msg
+}
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	_, _ = fmt.Fprint(s, e.msg)
+	if verb == 'v' && s.Flag('+') {
+		_, _ = fmt.Fprint(s, "\nmain.run\n\t/app/main.go:10")
+	}
+}
+
+func TestConsoleEncode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(CONSOLE), WithLoggerAppName("svc"))
+	logger.Infow("hello", Field{Key: "user", Value: "bob"}, Field{Key: "age", Value: 18})
+	logger.Notice("no fields")
+	logger.Errorw("failed", Field{Key: "error", Value: stackError{msg: "boom"}}, Field{Key: "id", Value: 1},
+		Field{Key: "sql", Value: "select *\nfrom t"})
+
+	lines := strings.Split(buf.String(), "\n")
+	patterns := []string{
+		`^ +\d+\.\d{3}s INFO   svc olog/console_test.go:35 +hello {35} user=bob age=18$`,
+		`^ +\d+\.\d{3}s NOTICE svc olog/console_test.go:36 +no fields$`,
+		`^ +\d+\.\d{3}s ERROR  svc olog/console_test.go:37 +failed {34} id=1$`,
+		`^    error: boom$`,
+		`^        main.run$`,
+		`^        \t/app/main.go:10$`,
+		`^    sql: select \*$`,
+		`^        from t$`,
+		`^$`,
+	}
+	if len(lines) != len(patterns) {
+		t.Fatalf("output = %q", buf.String())
+	}
+	for i, pattern := range patterns {
+		if !regexp.MustCompile(pattern).MatchString(lines[i]) {
+			t.Errorf("line %d = %q, want %s", i, lines[i], pattern)
+		}
+	}
+}
+
+func TestConsoleEncodeColor(t *testing.T) {
+	buf := encoder.NewBuffer(nil)
+	consoleEncode(Record{
+		Level:       WARN,
+		LevelTag:    "warn",
+		Caller:      Disable,
+		MsgOrFormat: "hello",
+		Fields:      []Field{{Key: "user", Value: "bob"}, {Key: "err", Value: errors.New("multi\nline")}},
+		Time:        consoleStart.Add(1234 * time.Millisecond),
+	}, buf, ThemeDefault)
+
+	want := Gray + "   1.234s" + Reset + " " + Yellow + "WARN" + Reset + "   hello" + strings.Repeat(" ", 35) + " " +
+		Cyan + "user" + Reset + "=" + Green + "bob" + Reset + "\n    " + Red + "err" + Reset + ": multi\n        line\n"
+	if got := string(buf.Bytes()); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestConsoleEncodeStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(CONSOLE), WithLoggerCaller(false))
+	logger.Log(Record{Level: DEBUG, Stack: Enable, StackSize: 1, MsgOrFormat: "hello"})
+
+	pattern := `^ +\d+\.\d{3}s DEBUG  hello\n    stack:\n        github.com/welllog/olog.TestConsoleEncodeStack\n` +
+		`            .+/console_test.go:\d+\n$`
+	if !regexp.MustCompile(pattern).MatchString(buf.String()) {
+		t.Errorf("output = %q, want %s", buf.String(), pattern)
+	}
+}
+
+func TestWriteConsoleElapsed(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Second, "   0.000s"},
+		{5 * time.Millisecond, "   0.005s"},
+		{12340 * time.Millisecond, "  12.340s"},
+		{12345 * time.Second, "12345.000s"},
+	}
+	for _, tt := range tests {
+		buf := encoder.NewBuffer(nil)
+		writeConsoleElapsed(encoder.PlainEncoder{Buffer: buf}, tt.d)
+		if got := string(buf.Bytes()); got != tt.want {
+			t.Errorf("writeConsoleElapsed(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestWriterColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if writerColor(NewWriter(f), INFO) {
+		t.Error("a regular file supports colors")
+	}
+	if writerColor(NewWriter(&bytes.Buffer{}), INFO) {
+		t.Error("a buffer supports colors")
+	}
+
+	cw := &consoleWriter{scolor: true}
+	if !writerColor(cw, INFO) || writerColor(cw, ERROR) {
+		t.Error("the color is not detected per stream")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "1")
+	if !autoColor(f) {
+		t.Error("colors are disabled with FORCE_COLOR")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if autoColor(f) {
+		t.Error("colors are enabled with NO_COLOR")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "0")
+	t.Setenv("TERM", "dumb")
+	if autoColor(os.Stdout) {
+		t.Error("colors are enabled with TERM=dumb")
+	}
+}
+
+func TestLoggerColor(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerTimeFormat(""),
+		WithLoggerCaller(false))
+	logger.Info("auto")
+
+	logger = NewLogger(WithLoggerWriter(&consoleWriter{sw: &buf, ew: &buf, ecolor: true}), WithLoggerEncode(PLAIN),
+		WithLoggerTimeFormat(""), WithLoggerCaller(false))
+	logger.Info("stdout")
+	logger.Error("stderr")
+
+	logger = NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerTimeFormat(""),
+		WithLoggerCaller(false), WithLoggerColor(true))
+	logger.Info("forced")
+
+	want := "\tinfo\tauto\n\tinfo\tstdout\n\t" + Red + "error" + Reset + "\tstderr\n\t" + Green + "info" + Reset + "\tforced\n"
+	if !colorSupported() {
+		want = "\tinfo\tauto\n\tinfo\tstdout\n\terror\tstderr\n\tinfo\tforced\n"
+	}
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
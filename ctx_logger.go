@@ -8,6 +8,8 @@ import (
 type ctxLogger struct {
 	Logger
 	fields []Field
+	last   []Field    // last is the fields in scope order, the outer scopes first, for DuplicateKeepLast
+	ns     *namespace // ns is the namespace the fields of the logging calls are nested in, nil if none
 }
 
 // WithContext creates a new logger with the provided context.
@@ -20,12 +22,7 @@ func WithContext(logger Logger, ctx context.Context, handles ...CtxHandle) Logge
 		handle = getDefCtxHandle()
 	}
 
-	fields := handle(ctx)
-
-	return &ctxLogger{
-		Logger: logger,
-		fields: logger.buildFields(fields...),
-	}
+	return newCtxLogger(logger, handle(ctx))
 }
 
 // WithEntries creates a new logger with the provided entries.
@@ -35,243 +32,333 @@ func WithEntries(logger Logger, entries map[string]any) Logger {
 		fields = append(fields, Field{Key: k, Value: v})
 	}
 
-	return &ctxLogger{
-		Logger: logger,
-		fields: logger.buildFields(fields...),
-	}
+	return newCtxLogger(logger, fields)
 }
 
 // WithFields creates a new logger with the provided fields.
 // It may update the provided fields by appending the existing fields from the logger.
 func WithFields(logger Logger, fields ...Field) Logger {
+	return newCtxLogger(logger, fields)
+}
+
+// newCtxLogger creates a new ctxLogger with the fields added to the logger, the fields are added to the
+// namespace of the logger if it has one.
+func newCtxLogger(logger Logger, fields []Field) *ctxLogger {
+	if c, ok := logger.(*ctxLogger); ok && c.ns != nil {
+		ns := c.ns.with(fields)
+		return &ctxLogger{
+			Logger: logger,
+			fields: ns.buildFields(nil),
+			last:   ns.lastFields(nil),
+			ns:     ns,
+		}
+	}
+
 	return &ctxLogger{
 		Logger: logger,
 		fields: logger.buildFields(fields...),
+		last:   lastFields(logger, fields),
 	}
 }
 
 func (c *ctxLogger) Log(r Record) {
 	if c.IsEnabled(r.Level) {
-		r.Fields = c.buildFields(r.Fields...)
+		if r.Duplicates == DuplicateKeepLast || r.Duplicates == DuplicateDefault && c.keepLast() {
+			r.Fields = lastFields(c, r.Fields)
+		} else {
+			r.Fields = c.buildFields(r.Fields...)
+		}
 		c.log(r)
 	}
 }
 
 func (c *ctxLogger) Fatal(args ...any) {
+	as := getArgs(args)
 	c.log(Record{
 		Level:   FATAL,
-		MsgArgs: args,
-		Fields:  c.fields,
+		MsgArgs: *as,
+		Fields:  c.scopeFields(),
 		OsExit:  true,
 	})
+	putArgs(as)
 }
 
 func (c *ctxLogger) Fatalf(format string, args ...any) {
+	as := getArgs(args)
 	c.log(Record{
 		Level:       FATAL,
 		MsgOrFormat: format,
-		MsgArgs:     args,
-		Fields:      c.fields,
+		MsgArgs:     *as,
+		Fields:      c.scopeFields(),
 		OsExit:      true,
 	})
+	putArgs(as)
 }
 
 func (c *ctxLogger) Fatalw(msg string, fields ...Field) {
+	fs := c.callFields(fields)
 	c.log(Record{
 		Level:       FATAL,
 		MsgOrFormat: msg,
-		Fields:      c.buildFields(fields...),
+		Fields:      *fs,
 		OsExit:      true,
 	})
+	putFields(fs)
 }
 
 func (c *ctxLogger) Error(args ...any) {
 	if c.IsEnabled(ERROR) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   ERROR,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Errorf(format string, args ...any) {
 	if c.IsEnabled(ERROR) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       ERROR,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Errorw(msg string, fields ...Field) {
 	if c.IsEnabled(ERROR) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       ERROR,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (c *ctxLogger) Warn(args ...any) {
 	if c.IsEnabled(WARN) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   WARN,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Warnf(format string, args ...any) {
 	if c.IsEnabled(WARN) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       WARN,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Warnw(msg string, fields ...Field) {
 	if c.IsEnabled(WARN) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       WARN,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (c *ctxLogger) Notice(args ...any) {
 	if c.IsEnabled(NOTICE) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   NOTICE,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Noticef(format string, args ...any) {
 	if c.IsEnabled(NOTICE) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       NOTICE,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Noticew(msg string, fields ...Field) {
 	if c.IsEnabled(NOTICE) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       NOTICE,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (c *ctxLogger) Info(args ...any) {
 	if c.IsEnabled(INFO) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   INFO,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Infof(format string, args ...any) {
 	if c.IsEnabled(INFO) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       INFO,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Infow(msg string, fields ...Field) {
 	if c.IsEnabled(INFO) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       INFO,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (c *ctxLogger) Debug(args ...any) {
 	if c.IsEnabled(DEBUG) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   DEBUG,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Debugf(format string, args ...any) {
 	if c.IsEnabled(DEBUG) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       DEBUG,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Debugw(msg string, fields ...Field) {
 	if c.IsEnabled(DEBUG) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       DEBUG,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (c *ctxLogger) Trace(args ...any) {
 	if c.IsEnabled(TRACE) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:   TRACE,
 			Stack:   Enable,
-			MsgArgs: args,
-			Fields:  c.fields,
+			MsgArgs: *as,
+			Fields:  c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Tracef(format string, args ...any) {
 	if c.IsEnabled(TRACE) {
+		as := getArgs(args)
 		c.log(Record{
 			Level:       TRACE,
 			Stack:       Enable,
 			MsgOrFormat: format,
-			MsgArgs:     args,
-			Fields:      c.fields,
+			MsgArgs:     *as,
+			Fields:      c.scopeFields(),
 		})
+		putArgs(as)
 	}
 }
 
 func (c *ctxLogger) Tracew(msg string, fields ...Field) {
 	if c.IsEnabled(TRACE) {
+		fs := c.callFields(fields)
 		c.log(Record{
 			Level:       TRACE,
 			Stack:       Enable,
 			MsgOrFormat: msg,
-			Fields:      c.buildFields(fields...),
+			Fields:      *fs,
 		})
+		putFields(fs)
+	}
+}
+
+// keepLast reports whether the duplicate policy of the logger is DuplicateKeepLast, the fields are in scope
+// order then, so that the fields of the logging call and the later scopes override the earlier ones.
+func (c *ctxLogger) keepLast() bool {
+	return c.duplicatePolicy() == DuplicateKeepLast
+}
+
+// scopeFields returns the fields of the logger in the order of the duplicate policy.
+func (c *ctxLogger) scopeFields() []Field {
+	if c.keepLast() {
+		return c.last
 	}
+	return c.fields
+}
+
+// callFields returns a pooled slice of the fields of the logging call followed by the fields of the logger,
+// or the fields of the logger followed by the fields of the logging call for DuplicateKeepLast.
+func (c *ctxLogger) callFields(fields []Field) *[]Field {
+	if c.keepLast() {
+		if c.ns != nil && len(fields) > 0 {
+			return getFields(c.ns.lastFields(fields), nil)
+		}
+		return getFields(c.last, fields)
+	}
+
+	if c.ns != nil && len(fields) > 0 {
+		return getFields(c.ns.buildFields(fields), nil)
+	}
+	return getFields(fields, c.fields)
 }
 
 // buildFields builds the final fields slice.
@@ -281,6 +368,30 @@ func (c *ctxLogger) buildFields(fields ...Field) []Field {
 		return c.fields
 	}
 
+	if c.ns != nil {
+		return c.ns.buildFields(fields)
+	}
+
 	// not modify the old fields, and ensure that the new field is in front.
 	return append(fields, c.fields...)
 }
+
+// lastFields returns the fields of the logger in scope order followed by the fields, which is the order of
+// the fields for DuplicateKeepLast.
+func lastFields(logger Logger, fields []Field) []Field {
+	c, ok := logger.(*ctxLogger)
+	if !ok {
+		return logger.buildFields(fields...)
+	}
+
+	if len(fields) == 0 {
+		return c.last
+	}
+
+	if c.ns != nil {
+		return c.ns.lastFields(fields)
+	}
+
+	// not modify the old fields, and ensure that the new field is at the end.
+	return append(c.last[:len(c.last):len(c.last)], fields...)
+}
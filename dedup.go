@@ -0,0 +1,104 @@
+package olog
+
+// DuplicatePolicy is the policy for the fields with duplicate keys, and the fields colliding with the keys
+// written by the encoding, such as "level" and "content".
+type DuplicatePolicy int8
+
+const (
+	// DuplicateDefault is the policy of the logger, which is DuplicateKeepFirst if it is not set.
+	DuplicateDefault DuplicatePolicy = iota
+	// DuplicateKeepFirst writes the first of the fields with the same key. The fields of the logging call
+	// are before the fields of WithFields and WithContext, so they take precedence.
+	DuplicateKeepFirst
+	// DuplicateKeepLast writes the last of the fields with the same key, at the position of the last one.
+	// The fields of WithFields and WithContext are in scope order then, the outer scopes first and the
+	// fields of the logging call last, so the request scoped fields override the base fields.
+	DuplicateKeepLast
+	// DuplicateKeepAll writes all the fields with the same key, the JSON objects may have duplicate keys.
+	DuplicateKeepAll
+	// DuplicateRename writes the fields with the duplicate keys and the keys of the encoding with the key
+	// prefixed by "fields.", such as "fields.level", the fields are dropped if the renamed key is also written.
+	DuplicateRename
+)
+
+// renamePrefix is the prefix of the keys of the fields renamed by DuplicateRename.
+const renamePrefix = "fields."
+
+// fieldDedup resolves the keys of the fields of a record by the duplicate policy. The fields colliding with
+// the reserved keys are dropped unless they are renamed.
+type fieldDedup struct {
+	policy   DuplicatePolicy
+	fields   []Field
+	reserved map[string]struct{}
+	set      fieldSet
+	last     map[string]int // the index of the last field of each key for DuplicateKeepLast with many fields
+}
+
+// newFieldDedup returns the fieldDedup of the fields, reserved is the set of the keys of the encoding.
+func newFieldDedup(policy DuplicatePolicy, fields []Field, reserved map[string]struct{}) fieldDedup {
+	return fieldDedup{policy: policy, fields: fields, reserved: reserved}
+}
+
+// key returns the key to write for the i-th field, and false if the field is dropped. The fields must be
+// checked in order.
+func (d *fieldDedup) key(i int) (string, bool) {
+	key := d.fields[i].Key
+	_, reserved := d.reserved[key]
+
+	switch d.policy {
+	case DuplicateKeepLast:
+		return key, !reserved && !d.hasLater(i)
+	case DuplicateKeepAll:
+		return key, !reserved
+	case DuplicateRename:
+		if !reserved && d.set.add(key) {
+			return key, true
+		}
+		key = renamePrefix + key
+		return key, d.set.add(key)
+	default:
+		return key, !reserved && d.set.add(key)
+	}
+}
+
+// hasLater reports whether a field after the i-th field has the same key.
+func (d *fieldDedup) hasLater(i int) bool {
+	key := d.fields[i].Key
+	if len(d.fields) <= smallFieldSet {
+		for _, field := range d.fields[i+1:] {
+			if field.Key == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	if d.last == nil {
+		d.last = make(map[string]int, len(d.fields))
+		for j, field := range d.fields {
+			d.last[field.Key] = j
+		}
+	}
+	return d.last[key] > i
+}
+
+// EachField calls fn with the fields of the record to write and their keys resolved by the Duplicates policy
+// of the record, the fields with duplicate keys or colliding with the keys of the encoding, such as "level"
+// and "content", are skipped or renamed. The groups are flattened to the fields with the keys prefixed by the
+// key of the group and "." if flatten is true, otherwise they are passed as they are. It is used by the
+// custom EncodeFunc to write the fields like the encodings of olog.
+func (r Record) EachField(flatten bool, fn func(key string, field Field)) {
+	fields := r.Fields
+	if flatten {
+		fs := flattenGroups(r.Fields)
+		defer putFields(fs)
+		fields = *fs
+	}
+
+	dedup := newFieldDedup(r.Duplicates, fields, filterField)
+	for i, field := range fields {
+		if key, ok := dedup.key(i); ok {
+			fn(key, field)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package olog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	fields := []Field{
+		{Key: "name", Value: "bob"},
+		{Key: "level", Value: "high"},
+		{Key: "age", Value: 18},
+		{Key: "name", Value: "alice"},
+	}
+
+	tests := []struct {
+		policy DuplicatePolicy
+		json   string
+		plain  string
+	}{
+		{
+			policy: DuplicateDefault,
+			json:   `,"name":"bob","age":18}` + "\n",
+			plain:  "\tname=bob\tage=18\n",
+		},
+		{
+			policy: DuplicateKeepFirst,
+			json:   `,"name":"bob","age":18}` + "\n",
+			plain:  "\tname=bob\tage=18\n",
+		},
+		{
+			policy: DuplicateKeepLast,
+			json:   `,"age":18,"name":"alice"}` + "\n",
+			plain:  "\tage=18\tname=alice\n",
+		},
+		{
+			policy: DuplicateKeepAll,
+			json:   `,"name":"bob","age":18,"name":"alice"}` + "\n",
+			plain:  "\tname=bob\tage=18\tname=alice\n",
+		},
+		{
+			policy: DuplicateRename,
+			json:   `,"name":"bob","fields.level":"high","age":18,"fields.name":"alice"}` + "\n",
+			plain:  "\tname=bob\tfields.level=high\tage=18\tfields.name=alice\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, encode := range []EncodeType{JSON, PLAIN} {
+			var buf bytes.Buffer
+			logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncode(encode),
+				WithLoggerDuplicatePolicy(tt.policy))
+			logger.Infow("test", fields...)
+
+			want := tt.json
+			if encode == PLAIN {
+				want = tt.plain
+			}
+			if !strings.HasSuffix(buf.String(), want) {
+				t.Errorf("policy %d encode %d: output = %q, want suffix %q", tt.policy, encode, buf.String(), want)
+			}
+		}
+	}
+}
+
+func TestDuplicatePolicyRecord(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerDuplicatePolicy(DuplicateKeepLast))
+	logger = WithContext(logger, context.Background())
+	logger = WithFields(logger, Field{Key: "user", Value: "base"})
+
+	logger.Infow("test", Field{Key: "user", Value: "request"})
+	if want := `,"user":"request"}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	WithFields(logger, Field{Key: "user", Value: "scope"}).Info("test")
+	if want := `,"user":"scope"}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger.Log(Record{Level: INFO, Duplicates: DuplicateKeepLast, MsgOrFormat: "test", Fields: []Field{{Key: "user", Value: "request"}}})
+	if want := `,"user":"request"}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger.Log(Record{Level: INFO, Duplicates: DuplicateKeepFirst, MsgOrFormat: "test", Fields: []Field{{Key: "user", Value: "request"}}})
+	if want := `,"user":"request"}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+}
+
+func TestDuplicateKeepLastMany(t *testing.T) {
+	var fields []Field
+	for i := 0; i < 2*smallFieldSet; i++ {
+		fields = append(fields, Field{Key: "k" + strconv.Itoa(i%smallFieldSet), Value: i})
+	}
+
+	dedup := newFieldDedup(DuplicateKeepLast, fields, filterField)
+	for i := range fields {
+		if _, ok := dedup.key(i); ok != (i >= smallFieldSet) {
+			t.Errorf("field %d kept = %t", i, ok)
+		}
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	fields := func() []Field {
+		return []Field{{Key: "a", Value: 1}, {Key: "content", Value: 2}, {Key: "a", Value: 3}, {Key: "b", Value: 4}}
+	}
+
+	tests := []struct {
+		policy []DuplicatePolicy
+		want   []Field
+	}{
+		{want: []Field{{Key: "a", Value: 1}, {Key: "b", Value: 4}}},
+		{policy: []DuplicatePolicy{DuplicateKeepLast}, want: []Field{{Key: "a", Value: 3}, {Key: "b", Value: 4}}},
+		{policy: []DuplicatePolicy{DuplicateKeepAll}, want: []Field{{Key: "a", Value: 1}, {Key: "a", Value: 3}, {Key: "b", Value: 4}}},
+		{
+			policy: []DuplicatePolicy{DuplicateRename},
+			want:   []Field{{Key: "a", Value: 1}, {Key: "fields.content", Value: 2}, {Key: "fields.a", Value: 3}, {Key: "b", Value: 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := FilterFields(fields(), tt.policy...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterFields(%v) = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestRecordEachField(t *testing.T) {
+	r := Record{
+		Duplicates: DuplicateKeepLast,
+		Fields: []Field{
+			{Key: "a", Value: 1}, {Key: "level", Value: 2}, {Key: "req", Value: Group{{Key: "id", Value: 3}}}, {Key: "a", Value: 4},
+		},
+	}
+
+	tests := []struct {
+		flatten bool
+		want    []string
+	}{
+		{flatten: false, want: []string{"req=[{id 3}]", "a=4"}},
+		{flatten: true, want: []string{"req.id=3", "a=4"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		r.EachField(tt.flatten, func(key string, field Field) {
+			got = append(got, key+"="+fmt.Sprint(field.Value))
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("EachField(%v) = %v, want %v", tt.flatten, got, tt.want)
+		}
+	}
+}
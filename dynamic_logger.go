@@ -7,6 +7,7 @@ type DynamicLogger struct {
 	ShortFile  EnableOp // ShortFile is the enable of short file name in the log message.
 	CallerSkip int8
 	app        string // app is the name of the application.
+	name       string // name is the hierarchical name of the logger created by Named.
 }
 
 // SetAppName sets the name of the application for the DynamicLogger.
@@ -17,7 +18,7 @@ func (d *DynamicLogger) SetAppName(name string) {
 }
 
 func (d DynamicLogger) Log(r Record) {
-	l := getDefLogger()
+	l := d.defLogger()
 	if l.IsEnabled(r.Level) {
 		if r.Caller == Default {
 			r.Caller = d.Caller
@@ -33,6 +34,7 @@ func (d DynamicLogger) Log(r Record) {
 
 		r.CallerSkip = defCallerSkip - 1 + d.CallerSkip + r.CallerSkip
 		r.App = d.app
+		r.Name = d.name
 
 		l.output(r)
 	}
@@ -209,25 +211,40 @@ func (d DynamicLogger) Tracew(msg string, fields ...Field) {
 }
 
 func (d DynamicLogger) IsEnabled(level Level) bool {
-	return getDefLogger().IsEnabled(level)
+	return d.defLogger().IsEnabled(level)
 }
 
 func (d DynamicLogger) log(r Record) {
 	r.CallerSkip++
-	getDefLogger().log(r)
+	d.defLogger().log(r)
 }
 
 func (d DynamicLogger) buildFields(fields ...Field) []Field {
 	return fields
 }
 
-func (d DynamicLogger) printRecord(r Record) {
+func (d DynamicLogger) duplicatePolicy() DuplicatePolicy {
+	return getDefLogger().duplicates
+}
+
+// defLogger returns the default logger, named by the name of the DynamicLogger with the options set by
+// WithLoggerNameOptions for the name and its parent names applied if it is named.
+func (d DynamicLogger) defLogger() *logger {
 	l := getDefLogger()
+	if d.name == "" {
+		return l
+	}
+	return l.withName(d.name, 1)
+}
+
+func (d DynamicLogger) printRecord(r Record) {
+	l := d.defLogger()
 	if l.IsEnabled(r.Level) {
 		r.Caller = d.Caller
 		r.ShortFile = d.ShortFile
 		r.CallerSkip = defCallerSkip + d.CallerSkip
 		r.App = d.app
+		r.Name = d.name
 		l.output(r)
 	}
 }
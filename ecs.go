@@ -0,0 +1,199 @@
+package olog
+
+import (
+	"reflect"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// ecsVersion is the version of the Elastic Common Schema written by EcsEncode.
+const ecsVersion = "8.11.0"
+
+// ecsTimeFormat is the ISO 8601 format of the @timestamp written by EcsEncode.
+const ecsTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ecsReserved is the set of top level keys written by EcsEncode, the fields with these keys are skipped. The
+// fields with the key "error" are promoted to the error object instead.
+var ecsReserved = map[string]struct{}{
+	"@timestamp": {},
+	"log":        {},
+	"message":    {},
+	"ecs":        {},
+	"service":    {},
+	"trace":      {},
+	"span":       {},
+}
+
+// ecsPromoted is the fields of a record promoted to the error, trace and span objects of the ECS document.
+type ecsPromoted struct {
+	err, trace, span *Field
+	errKeyed         bool // errKeyed is whether err is the field with the key "error"
+}
+
+// pick chooses the fields to promote among the fields left by the policy, the field with the key "error"
+// or else an error value is promoted to the error object, and the fields with the trace and span id keys,
+// including the ones in the groups, to the trace and span objects. The last candidates are promoted with
+// DuplicateKeepLast, and the first ones otherwise.
+func (p *ecsPromoted) pick(fields []Field, reserved map[string]struct{}, policy DuplicatePolicy, top bool) {
+	last := policy == DuplicateKeepLast
+	dedup := newFieldDedup(policy, fields, reserved)
+	for i := range fields {
+		key, ok := dedup.key(i)
+		if !ok {
+			continue
+		}
+
+		field := &fields[i]
+		if group, ok := field.Value.(Group); ok {
+			p.pick(group, nil, policy, false)
+			continue
+		}
+
+		if top {
+			if key == "error" {
+				if !p.errKeyed || last {
+					p.err, p.errKeyed = field, true
+				}
+				continue
+			}
+			if _, ok := field.Value.(error); ok {
+				if p.err == nil || (last && !p.errKeyed) {
+					p.err = field
+				}
+				continue
+			}
+		}
+
+		if _, ok := traceIDKeys[key]; ok {
+			if p.trace == nil || last {
+				p.trace = field
+			}
+		} else if _, ok = spanIDKeys[key]; ok {
+			if p.span == nil || last {
+				p.span = field
+			}
+		}
+	}
+}
+
+// EcsEncode encodes the Record as an Elastic Common Schema document to the buffer, with nested objects
+// rather than dotted keys. The level is written to log.level, the caller to log.origin, the name of the
+// logger to log.logger, the app to service.name and the stack to error.stack_trace. The field with the key
+// "error", or else a field with an error value, is written to error.message and error.type, and the fields
+// such as trace_id and span_id, including the ones in the groups, are written to trace.id and span.id. The
+// promoted fields are chosen among the fields left by the duplicate policy, the last ones with
+// DuplicateKeepLast and the first ones otherwise. The other fields are written as top level keys. It can be
+// used as the EncodeFunc of the logger.
+func EcsEncode(r Record, buf *encoder.Buffer) {
+	enc := encoder.JsonEncoder{Buffer: buf}
+
+	var p ecsPromoted
+	p.pick(r.Fields, ecsReserved, r.Duplicates, true)
+
+	_, _ = enc.WriteString(`{"@timestamp":"`)
+	enc.WriteTime(r.Time, ecsTimeFormat)
+	_, _ = enc.WriteString(`","log":{"level":"`)
+	_, _ = enc.WriteString(r.LevelTag)
+	enc.WriteQuote()
+
+	if r.Name != "" {
+		_, _ = enc.WriteString(`,"logger":"`)
+		_, _ = enc.WriteString(r.Name)
+		enc.WriteQuote()
+	}
+
+	frame, frames, more := r.FirstFrame()
+
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		_, _ = enc.WriteString(`,"origin":{"file":{"name":"`)
+		enc.WriteEscapedString(file)
+		_, _ = enc.WriteString(`","line":`)
+		enc.WriteInt64(int64(frame.Line))
+		_, _ = enc.WriteString(`},"function":"`)
+		enc.WriteEscapedString(frame.Function)
+		_, _ = enc.WriteString(`"}`)
+	}
+
+	_, _ = enc.WriteString(`},"message":"`)
+	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	_, _ = enc.WriteString(`","ecs":{"version":"` + ecsVersion + `"}`)
+
+	if r.App != "" {
+		_, _ = enc.WriteString(`,"service":{"name":"`)
+		_, _ = enc.WriteString(r.App)
+		_, _ = enc.WriteString(`"}`)
+	}
+
+	stack := r.Stack.IsOpen() && frame.PC != 0
+	if p.err != nil || stack {
+		_, _ = enc.WriteString(`,"error":{`)
+		if p.err != nil {
+			_, _ = enc.WriteString(`"message":`)
+			if err, ok := p.err.Value.(error); ok {
+				enc.WriteValue(err.Error())
+				_, _ = enc.WriteString(`,"type":"`)
+				enc.WriteEscapedString(reflect.TypeOf(err).String())
+				enc.WriteQuote()
+			} else {
+				enc.WriteValue(p.err.Value)
+			}
+		}
+
+		if stack {
+			if p.err != nil {
+				enc.WriteSeparator()
+			}
+			_, _ = enc.WriteString(`"stack_trace":"`)
+			WriteStackText(buf, r.StackOpts, frame, frames, more, false, true)
+			enc.WriteQuote()
+		}
+		_ = enc.WriteByte('}')
+	}
+
+	if p.trace != nil {
+		_, _ = enc.WriteString(`,"trace":{"id":`)
+		enc.WriteValue(p.trace.Value)
+		_ = enc.WriteByte('}')
+	}
+
+	if p.span != nil {
+		_, _ = enc.WriteString(`,"span":{"id":`)
+		enc.WriteValue(p.span.Value)
+		_ = enc.WriteByte('}')
+	}
+
+	p.writeFields(enc, r.Fields, ecsReserved, r.Duplicates, true)
+	_, _ = enc.WriteString("}\n")
+}
+
+// writeFields writes the fields left by the policy except the promoted ones, the groups are written as nested
+// objects. The fields with the key "error" which are not promoted are skipped, since the key is the error
+// object, and they are only left by DuplicateKeepAll.
+func (p *ecsPromoted) writeFields(enc encoder.JsonEncoder, fields []Field, reserved map[string]struct{},
+	policy DuplicatePolicy, comma bool) {
+	top := reserved != nil
+	dedup := newFieldDedup(policy, fields, reserved)
+	for i := range fields {
+		key, ok := dedup.key(i)
+		field := &fields[i]
+		if !ok || field == p.err || field == p.trace || field == p.span || (top && key == "error") {
+			continue
+		}
+
+		if comma {
+			enc.WriteSeparator()
+		}
+		comma = true
+		enc.WriteQuote()
+		enc.WriteEscapedString(key)
+		_, _ = enc.WriteString(`":`)
+		if group, ok := field.Value.(Group); ok {
+			enc.StartObject()
+			p.writeFields(enc, group, nil, policy, false)
+			enc.EndObject()
+		} else {
+			writeJSONValue(enc, field.Value, policy, false)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEcsEncode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncodeFunc(EcsEncode), WithLoggerAppName("svc"))
+	logger.Errorw("request failed",
+		Field{Key: "error", Value: errors.New("boom")},
+		Field{Key: "trace_id", Value: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		Field{Key: "span_id", Value: "00f067aa0ba902b7"},
+		Field{Key: "user", Value: "bob"},
+		Field{Key: "user", Value: "alice"},
+		Field{Key: "message", Value: "dropped"},
+	)
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, m["@timestamp"].(string))
+	if err != nil || time.Since(ts) > 5*time.Second {
+		t.Errorf("@timestamp = %v, err = %v", m["@timestamp"], err)
+	}
+	delete(m, "@timestamp")
+
+	origin := m["log"].(map[string]any)["origin"].(map[string]any)
+	if line, _ := origin["file"].(map[string]any)["line"].(float64); line != 16 {
+		t.Errorf("log.origin.file.line = %v, want 16", line)
+	}
+	delete(origin["file"].(map[string]any), "line")
+
+	want := map[string]any{
+		"log": map[string]any{
+			"level": "error",
+			"origin": map[string]any{
+				"file":     map[string]any{"name": "olog/ecs_test.go"},
+				"function": "github.com/welllog/olog.TestEcsEncode",
+			},
+		},
+		"message": "request failed",
+		"ecs":     map[string]any{"version": ecsVersion},
+		"service": map[string]any{"name": "svc"},
+		"error":   map[string]any{"message": "boom", "type": "*errors.errorString"},
+		"trace":   map[string]any{"id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		"span":    map[string]any{"id": "00f067aa0ba902b7"},
+		"user":    "bob",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("document = %v, want %v", m, want)
+	}
+}
+
+func TestEcsEncodeStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncodeFunc(EcsEncode), WithLoggerCaller(false))
+	logger.Log(Record{Level: WARN, Stack: Enable, StackSize: 1, MsgOrFormat: "hello \"%s\"", MsgArgs: []any{"world"},
+		Fields: []Field{{Key: "error", Value: "not an error"}}})
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+
+	if m["message"] != `hello "world"` {
+		t.Errorf("message = %v", m["message"])
+	}
+	if _, ok := m["log"].(map[string]any)["origin"]; ok {
+		t.Errorf("log.origin is written with caller disabled")
+	}
+	if _, ok := m["service"]; ok {
+		t.Errorf("service is written without app")
+	}
+
+	e := m["error"].(map[string]any)
+	if e["message"] != "not an error" {
+		t.Errorf("error.message = %v", e["message"])
+	}
+	if _, ok := e["type"]; ok {
+		t.Errorf("error.type is written for a string error")
+	}
+	if s, _ := e["stack_trace"].(string); !strings.HasPrefix(s, "github.com/welllog/olog.TestEcsEncodeStack\n\t") {
+		t.Errorf("error.stack_trace = %q", s)
+	}
+}
+
+func TestEcsEncodePromoted(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DuplicatePolicy
+		ns     string
+		fields []Field
+		want   map[string]any
+	}{
+		{
+			name:   "keep last",
+			policy: DuplicateKeepLast,
+			fields: []Field{
+				{Key: "trace_id", Value: "a"}, {Key: "err", Value: errors.New("first")}, {Key: "trace_id", Value: "b"},
+				{Key: "cause", Value: errors.New("last")},
+			},
+			want: map[string]any{
+				"trace": map[string]any{"id": "b"},
+				"error": map[string]any{"message": "last", "type": "*errors.errorString"},
+				"err":   "first",
+			},
+		},
+		{
+			name:   "error key",
+			fields: []Field{{Key: "err", Value: errors.New("boom")}, {Key: "error", Value: "failed"}},
+			want: map[string]any{
+				"error": map[string]any{"message": "failed"},
+				"err":   "boom",
+			},
+		},
+		{
+			name:   "group",
+			ns:     "req",
+			fields: []Field{{Key: "id", Value: 1}, {Key: "ctx", Value: Group{{Key: "span_id", Value: "s"}}}, {Key: "traceId", Value: "t"}},
+			want: map[string]any{
+				"trace": map[string]any{"id": "t"},
+				"span":  map[string]any{"id": "s"},
+				"req":   map[string]any{"id": float64(1), "ctx": map[string]any{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncodeFunc(EcsEncode), WithLoggerCaller(false),
+			WithLoggerDuplicatePolicy(tt.policy))
+		WithNamespace(logger, tt.ns).Infow("hello", tt.fields...)
+
+		m := make(map[string]any)
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("%s: unmarshal %s: %v", tt.name, buf.Bytes(), err)
+		}
+		for _, key := range []string{"@timestamp", "log", "message", "ecs"} {
+			delete(m, key)
+		}
+		if !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("%s: document = %v, want %v", tt.name, m, tt.want)
+		}
+	}
+}
@@ -1,10 +1,6 @@
 package olog
 
-import (
-	"runtime"
-
-	"github.com/welllog/olog/encoder"
-)
+import "github.com/welllog/olog/encoder"
 
 // EncodeType is an enumeration type for different encoding types.
 type EncodeType int8
@@ -14,38 +10,76 @@ const (
 	JSON EncodeType = iota
 	// PLAIN represents the plain text encoding type.
 	PLAIN
+	// CONSOLE represents the human-friendly console encoding type for development, with aligned columns,
+	// relative timestamps and colors.
+	CONSOLE
 )
 
-func FilterFields(fields []Field) []Field {
+// FilterFields removes the fields colliding with the keys of the encoding and the fields with duplicate keys
+// in place by the policy, which is DuplicateKeepFirst if it is not provided. The fields are renamed in place
+// by DuplicateRename.
+func FilterFields(fields []Field, policy ...DuplicatePolicy) []Field {
 	n := len(fields)
 	if n == 0 {
 		return fields
 	}
 
-	set := make(map[string]struct{}, n)
+	var p DuplicatePolicy
+	if len(policy) > 0 {
+		p = policy[0]
+	}
+
+	dedup := newFieldDedup(p, fields, filterField)
+	keep := make([]bool, n)
+	keys := make([]string, n)
+	for i := range fields {
+		keys[i], keep[i] = dedup.key(i)
+	}
+
 	var remain int
-	for idx, field := range fields {
-		if !isSkipField(set, field.Key) {
-			fields[remain], fields[idx] = fields[idx], fields[remain]
+	for i, field := range fields {
+		if keep[i] {
+			field.Key = keys[i]
+			fields[remain] = field
 			remain++
 		}
 	}
 	return fields[:remain]
 }
 
-func isSkipField(keysSet map[string]struct{}, key string) bool {
-	_, ok := filterField[key]
-	if ok {
+// smallFieldSet is the number of the keys of a fieldSet kept in an array before a map is allocated.
+const smallFieldSet = 16
+
+// fieldSet is the set of the keys of the written fields, the keys are searched linearly in an array for the
+// small number of fields so that encoding a record does not allocate a map.
+type fieldSet struct {
+	n    int
+	keys [smallFieldSet]string
+	m    map[string]struct{}
+}
+
+// add adds the key to the set, and reports whether the key is not in the set before.
+func (s *fieldSet) add(key string) bool {
+	for i := 0; i < s.n; i++ {
+		if s.keys[i] == key {
+			return false
+		}
+	}
+	if s.m != nil {
+		if _, ok := s.m[key]; ok {
+			return false
+		}
+		s.m[key] = struct{}{}
 		return true
 	}
 
-	_, ok = keysSet[key]
-	if ok {
+	if s.n < smallFieldSet {
+		s.keys[s.n] = key
+		s.n++
 		return true
 	}
-
-	keysSet[key] = struct{}{}
-	return false
+	s.m = map[string]struct{}{key: {}}
+	return true
 }
 
 var (
@@ -54,6 +88,7 @@ var (
 	fieldApp     = "app"
 	fieldContent = "content"
 	fieldCaller  = "caller"
+	fieldLogger  = "logger"
 	fieldStack   = "stack"
 
 	filterField = map[string]struct{}{
@@ -62,10 +97,18 @@ var (
 		fieldApp:     {},
 		fieldContent: {},
 		fieldCaller:  {},
+		fieldLogger:  {},
 		fieldStack:   {},
 	}
 )
 
+// traceIDKeys and spanIDKeys are the field keys recognized as the trace id and span id by the encoders
+// supporting trace correlation.
+var (
+	traceIDKeys = map[string]struct{}{"trace_id": {}, "trace.id": {}, "traceId": {}, "traceID": {}}
+	spanIDKeys  = map[string]struct{}{"span_id": {}, "span.id": {}, "spanId": {}, "spanID": {}}
+)
+
 // jsonEncode to encode a Record object as JSON to the buffer.
 func jsonEncode(r Record, buf *encoder.Buffer) {
 	enc := encoder.JsonEncoder{Buffer: buf}
@@ -80,133 +123,197 @@ func jsonEncode(r Record, buf *encoder.Buffer) {
 		_, _ = enc.WriteString(r.App)
 	}
 
-	var (
-		more  bool
-		frame runtime.Frame
-	)
-	frames := r.Frames()
-	if frames != nil {
-		frame, more = frames.Next()
+	if r.Name != "" {
+		_, _ = enc.WriteString(`","logger":"`)
+		_, _ = enc.WriteString(r.Name)
 	}
 
+	frame, frames, more := r.FirstFrame()
+
 	if r.Caller.IsOpen() {
-		file := frame.File
-		if r.ShortFile.IsOpen() {
-			file = shortFile(file)
+		if r.callerSplit() {
+			_, _ = enc.WriteString(`","file":"`)
+			enc.WriteEscapedString(r.CallerFile(frame))
+			_, _ = enc.WriteString(`","line":`)
+			enc.WriteInt64(int64(frame.Line))
+			_, _ = enc.WriteString(`,"func":"`)
+			enc.WriteEscapedString(funcName(frame.Function))
+		} else {
+			_, _ = enc.WriteString(`","caller":"`)
+			enc.WriteEscapedString(r.caller(frame))
 		}
-		_, _ = enc.WriteString(`","caller":"`)
-		enc.WriteEscapedString(file)
-		_, _ = enc.WriteString(`:`)
-		enc.WriteInt64(int64(frame.Line))
 	}
 
 	_, _ = enc.WriteString(`","content":"`)
 	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
 	enc.WriteQuote()
 
-	set := make(map[string]struct{}, len(r.Fields))
-	for _, field := range r.Fields {
-		if !isSkipField(set, field.Key) {
-			_, _ = enc.WriteString(`,"`)
-			enc.WriteEscapedString(field.Key)
-			_, _ = enc.WriteString(`":`)
-			enc.WriteValue(field.Value)
-		}
-	}
+	writeJSONFields(enc, r.Fields, filterField, r.Duplicates, r.RichError.IsOpen(), true)
 
 	if r.Stack.IsOpen() {
-		_, _ = enc.WriteString(`,"stack":"`)
-		if frame.PC != 0 {
-			for {
-				_, _ = enc.WriteString(`\n`)
-				enc.WriteEscapedString(frame.Function)
-				_, _ = enc.WriteString(`\n\t`)
-				enc.WriteEscapedString(frame.File)
-				_ = enc.WriteByte(':')
-				enc.WriteInt64(int64(frame.Line))
-
-				if !more {
-					break
-				}
-				frame, more = frames.Next()
+		opts := r.StackOpts
+		if opts != nil && opts.Frames {
+			if opts.Goroutine {
+				_, _ = enc.WriteString(`,"goroutine":`)
+				enc.WriteUint64(goroutineID())
 			}
+			_, _ = enc.WriteString(`,"stack":[`)
+			var n int
+			opts.eachFrame(frame, frames, more, func(function, file string, line int) {
+				if n > 0 {
+					enc.WriteSeparator()
+				}
+				n++
+				writeJSONFrame(enc, function, file, line)
+			})
+			enc.EndArray()
+		} else {
+			_, _ = enc.WriteString(`,"stack":"`)
+			WriteStackText(buf, opts, frame, frames, more, true, true)
+			enc.WriteQuote()
 		}
-		enc.WriteQuote()
 	}
 
 	// Write the closing curly brace and newline character to the buffer.
 	_, _ = enc.WriteString("}\n")
 }
 
-// plainEncode to encode a Record object as plain text to the buffer.
-func plainEncode(r Record, buf *encoder.Buffer, enableColor bool) {
+// writeJSONFields writes the fields as the members of a JSON object, the groups are written as nested objects
+// with their fields deduplicated separately. The comma is written before the first field if comma is true.
+func writeJSONFields(enc encoder.JsonEncoder, fields []Field, reserved map[string]struct{}, policy DuplicatePolicy,
+	rich, comma bool) {
+	dedup := newFieldDedup(policy, fields, reserved)
+	for i, field := range fields {
+		key, ok := dedup.key(i)
+		if !ok {
+			continue
+		}
+
+		if comma {
+			enc.WriteSeparator()
+		}
+		comma = true
+		enc.WriteQuote()
+		enc.WriteEscapedString(key)
+		_, _ = enc.WriteString(`":`)
+		writeJSONValue(enc, field.Value, policy, rich)
+	}
+}
+
+// writeJSONValue writes the value of a field, the groups are written as nested objects with the fields
+// deduplicated by the policy, and the errors are written with their causes and stacks if rich.
+func writeJSONValue(enc encoder.JsonEncoder, value any, policy DuplicatePolicy, rich bool) {
+	switch value := value.(type) {
+	case Group:
+		enc.StartObject()
+		writeJSONFields(enc, value, nil, policy, rich, false)
+		enc.EndObject()
+	case []GoroutineFrame:
+		enc.StartArray()
+		for i, frame := range value {
+			if i > 0 {
+				enc.WriteSeparator()
+			}
+			writeJSONFrame(enc, frame.Function, frame.File, frame.Line)
+		}
+		enc.EndArray()
+	case error:
+		if rich {
+			writeJSONError(enc, value)
+		} else {
+			enc.WriteValue(value)
+		}
+	default:
+		enc.WriteValue(value)
+	}
+}
+
+// WriteJSONValue writes the value of a field as JSON like the JSON encoding, the groups are written as nested
+// objects with their fields resolved by the policy.
+func WriteJSONValue(enc encoder.JsonEncoder, value any, policy DuplicatePolicy) {
+	writeJSONValue(enc, value, policy, false)
+}
+
+// writeJSONFrame writes the frame as a {function, file, line} object.
+func writeJSONFrame(enc encoder.JsonEncoder, function, file string, line int) {
+	_, _ = enc.WriteString(`{"function":"`)
+	enc.WriteEscapedString(function)
+	_, _ = enc.WriteString(`","file":"`)
+	enc.WriteEscapedString(file)
+	_, _ = enc.WriteString(`","line":`)
+	enc.WriteInt64(int64(line))
+	enc.EndObject()
+}
+
+// plainEncode to encode a Record object as plain text to the buffer, the elements are colored by the theme
+// unless it is nil.
+func plainEncode(r Record, buf *encoder.Buffer, theme *Theme) {
 	enc := encoder.PlainEncoder{Buffer: buf}
+	if theme == nil {
+		theme = noTheme
+	}
 
-	enc.WriteTime(r.Time, r.TimeFmt)
-	enc.WriteSeparator()
-	if enableColor {
-		writeLevelWithColor(r.Level, r.LevelTag, enc)
+	if theme.Time != "" && r.TimeFmt != "" {
+		_, _ = enc.WriteString(theme.Time)
+		enc.WriteTime(r.Time, r.TimeFmt)
+		_, _ = enc.WriteString(Reset)
 	} else {
-		_, _ = enc.WriteString(r.LevelTag)
+		enc.WriteTime(r.Time, r.TimeFmt)
 	}
 	enc.WriteSeparator()
+	writeColored(enc, theme.levelColor(r.Level), r.LevelTag)
+	enc.WriteSeparator()
 
 	if r.App != "" {
-		_, _ = enc.WriteString(r.App)
+		writeColored(enc, theme.App, r.App)
 		enc.WriteSeparator()
 	}
 
-	var (
-		more  bool
-		frame runtime.Frame
-	)
-	frames := r.Frames()
-	if frames != nil {
-		frame, more = frames.Next()
+	if r.Name != "" {
+		writeColored(enc, theme.App, r.Name)
+		enc.WriteSeparator()
 	}
 
+	frame, frames, more := r.FirstFrame()
+
 	if r.Caller.IsOpen() {
-		file := frame.File
-		if r.ShortFile.IsOpen() {
-			file = shortFile(file)
+		_, _ = enc.WriteString(theme.Caller)
+		_, _ = enc.WriteString(r.caller(frame))
+		if theme.Caller != "" {
+			_, _ = enc.WriteString(Reset)
 		}
-
-		_, _ = enc.WriteString(file)
-		_ = enc.WriteByte(':')
-		enc.WriteInt64(int64(frame.Line))
 		enc.WriteSeparator()
 	}
 
+	_, _ = enc.WriteString(theme.Message)
 	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	if theme.Message != "" {
+		_, _ = enc.WriteString(Reset)
+	}
 
-	set := make(map[string]struct{}, len(r.Fields))
+	dedup := newFieldDedup(r.Duplicates, r.Fields, filterField)
 	// Loop over the fields of the Record object and write them to the buffer as plain text.
-	for _, field := range r.Fields {
-		if !isSkipField(set, field.Key) {
+	for i, field := range r.Fields {
+		if key, ok := dedup.key(i); ok {
 			enc.WriteSeparator()
-			enc.WriteName(field.Key)
-			enc.WriteValue(field.Value)
+			writeColored(enc, theme.Key, key)
+			_ = enc.WriteByte('=')
+			_, _ = enc.WriteString(theme.Value)
+			if err, ok := field.Value.(error); ok && r.RichError.IsOpen() {
+				writePlainError(enc, err, "\n\t", "\t")
+			} else {
+				enc.WriteValue(field.Value)
+			}
+			if theme.Value != "" {
+				_, _ = enc.WriteString(Reset)
+			}
 		}
 	}
 
 	if r.Stack.IsOpen() {
 		enc.WriteSeparator()
 		_, _ = enc.WriteString("stack=")
-		if frame.PC != 0 {
-			for {
-				_ = enc.WriteByte('\n')
-				_, _ = enc.WriteString(frame.Function)
-				_, _ = enc.WriteString("\n\t")
-				_, _ = enc.WriteString(frame.File)
-				_ = enc.WriteByte(':')
-				enc.WriteInt64(int64(frame.Line))
-
-				if !more {
-					break
-				}
-				frame, more = frames.Next()
-			}
-		}
+		WriteStackText(buf, r.StackOpts, frame, frames, more, true, false)
 	}
 
 	// Write the newline character to the buffer.
@@ -6,8 +6,48 @@ import (
 	"unsafe"
 )
 
+// fastWriter is implemented by the encoders to format the common values without fmt, the text is written
+// with the escaping of the encoding.
+type fastWriter interface {
+	Len() int
+	WriteInt64(n int64)
+	WriteUint64(n uint64)
+	WriteBool(v bool)
+	writeText(s string)
+	writeFloatValue(f float64, bitSize int)
+}
+
+func (j JsonEncoder) writeText(s string) {
+	j.WriteEscapedString(s)
+}
+
+func (j JsonEncoder) writeFloatValue(f float64, bitSize int) {
+	j.Buffer.WriteFloat(f, 'g', bitSize)
+}
+
+func (p PlainEncoder) writeText(s string) {
+	_, _ = p.WriteString(s)
+}
+
+func (p PlainEncoder) writeFloatValue(f float64, bitSize int) {
+	p.Buffer.WriteFloat(f, 'g', bitSize)
+}
+
 func EPrint(w io.Writer, args ...any) (n int, err error) {
-	return fmt.Fprint(w, args...)
+	fw, ok := w.(fastWriter)
+	if !ok || !fastArgs(args) {
+		return fmt.Fprint(w, args...)
+	}
+
+	start := fw.Len()
+	for i, arg := range args {
+		// like fmt.Print, the spaces are added between the operands when neither is a string.
+		if i > 0 && !isString(arg) && !isString(args[i-1]) {
+			fw.writeText(" ")
+		}
+		writeFastValue(fw, arg)
+	}
+	return fw.Len() - start, nil
 }
 
 func EPrintf(w io.Writer, format string, args ...any) (n int, err error) {
@@ -25,8 +65,122 @@ func EPrintf(w io.Writer, format string, args ...any) (n int, err error) {
 	}
 
 	if format == "" {
-		return fmt.Fprint(w, args...)
+		return EPrint(w, args...)
+	}
+
+	fw, ok := w.(fastWriter)
+	if !ok || !fastFormat(format, args) {
+		return fmt.Fprintf(w, format, args...)
+	}
+
+	start := fw.Len()
+	var argNum, last int
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if last < i {
+			fw.writeText(format[last:i])
+		}
+		i++
+		if format[i] == '%' {
+			fw.writeText("%")
+		} else {
+			writeFastValue(fw, args[argNum])
+			argNum++
+		}
+		last = i + 1
+	}
+	if last < len(format) {
+		fw.writeText(format[last:])
 	}
+	return fw.Len() - start, nil
+}
+
+// fastFormat reports whether the format can be formatted without fmt, it only has the verbs %s, %d, %t, %v
+// and %% without flags, and the args are the common values matching the verbs.
+func fastFormat(format string, args []any) bool {
+	var argNum int
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i == len(format) {
+			return false
+		}
+		if format[i] == '%' {
+			continue
+		}
+		if argNum == len(args) || !fastVerb(format[i], args[argNum]) {
+			return false
+		}
+		argNum++
+	}
+	return argNum == len(args)
+}
 
-	return fmt.Fprintf(w, format, args...)
+// fastArgs reports whether the args are all the common values formatted without fmt.
+func fastArgs(args []any) bool {
+	for _, arg := range args {
+		if !fastVerb('v', arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// fastVerb reports whether the arg is a common value formatted by the verb without fmt.
+func fastVerb(verb byte, arg any) bool {
+	switch arg.(type) {
+	case string:
+		return verb == 's' || verb == 'v'
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return verb == 'd' || verb == 'v'
+	case bool:
+		return verb == 't' || verb == 'v'
+	case float32, float64:
+		return verb == 'v'
+	default:
+		return false
+	}
+}
+
+func isString(arg any) bool {
+	_, ok := arg.(string)
+	return ok
+}
+
+// writeFastValue writes the common value as formatted by fmt with %v.
+func writeFastValue(w fastWriter, arg any) {
+	switch v := arg.(type) {
+	case string:
+		w.writeText(v)
+	case int:
+		w.WriteInt64(int64(v))
+	case int8:
+		w.WriteInt64(int64(v))
+	case int16:
+		w.WriteInt64(int64(v))
+	case int32:
+		w.WriteInt64(int64(v))
+	case int64:
+		w.WriteInt64(v)
+	case uint:
+		w.WriteUint64(uint64(v))
+	case uint8:
+		w.WriteUint64(uint64(v))
+	case uint16:
+		w.WriteUint64(uint64(v))
+	case uint32:
+		w.WriteUint64(uint64(v))
+	case uint64:
+		w.WriteUint64(v)
+	case bool:
+		w.WriteBool(v)
+	case float32:
+		w.writeFloatValue(float64(v), 32)
+	case float64:
+		w.writeFloatValue(v, 64)
+	}
 }
@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -134,7 +135,8 @@ func TestEPrintfNoArgs(t *testing.T) {
 
 func TestEPrintfEmptyFormat(t *testing.T) {
 	buf := PlainEncoder{&Buffer{}}
-	n, err := EPrintf(buf, "", "hello", "world")
+	format := ""
+	n, err := EPrintf(buf, format, "hello", "world")
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -159,3 +161,57 @@ func TestEPrintfNoArgsEmptyFormat(t *testing.T) {
 		t.Errorf("unexpected output: %q", string(buf.Bytes()))
 	}
 }
+
+func TestEPrintfFastPath(t *testing.T) {
+	tests := []struct {
+		f string
+		a []any
+	}{
+		{f: "%s=%d", a: []any{"a", 1}},
+		{f: "%v %v %v %v", a: []any{int8(-8), uint16(16), float32(1.5), 2.25}},
+		{f: "%t%%%v", a: []any{true, false}},
+		{f: "%d%s", a: []any{uint64(1 << 63), ""}},
+		{f: "%5d", a: []any{1}},
+		{f: "%s", a: []any{1}},
+		{f: "%d", a: []any{1.5}},
+		{f: "%s %s", a: []any{"a"}},
+		{f: "%s", a: []any{"a", "b"}},
+		{f: "%v", a: []any{[]int{1}}},
+		{f: "100%", a: []any{1}},
+		{a: []any{1, 2, "a", 3, true, 1.5}},
+		{a: []any{"a", "b", nil}},
+	}
+
+	for _, tt := range tests {
+		e := PlainEncoder{&Buffer{}}
+		n, err := EPrintf(e, tt.f, tt.a...)
+		want := fmt.Sprintf(tt.f, tt.a...)
+		if tt.f == "" {
+			want = fmt.Sprint(tt.a...)
+		}
+		if err != nil || n != len(want) || string(e.Bytes()) != want {
+			t.Errorf("EPrintf(%q, %v) = %q, %d, %v, want %q", tt.f, tt.a, e.Bytes(), n, err, want)
+		}
+	}
+}
+
+func TestEPrintfAllocs(t *testing.T) {
+	e := JsonEncoder{&Buffer{}}
+	allocs := testing.AllocsPerRun(100, func() {
+		e.Reset()
+		_, _ = EPrintf(e, "user %s logged in %d times, admin: %t", "alice", 3, false)
+		_, _ = EPrint(e, "took", 1.5, "ms")
+	})
+	if allocs != 0 {
+		t.Errorf("allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkEPrintf(b *testing.B) {
+	e := JsonEncoder{&Buffer{}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.Reset()
+		_, _ = EPrintf(e, "user %s logged in %d times", "alice", 3)
+	}
+}
@@ -0,0 +1,227 @@
+package encoder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MsgpackEncoder encodes values in the MessagePack format, it only supports the types needed by logging.
+type MsgpackEncoder struct {
+	*Buffer
+}
+
+func (m MsgpackEncoder) WriteNil() {
+	_ = m.WriteByte(0xc0)
+}
+
+func (m MsgpackEncoder) WriteBool(v bool) {
+	if v {
+		_ = m.WriteByte(0xc3)
+	} else {
+		_ = m.WriteByte(0xc2)
+	}
+}
+
+func (m MsgpackEncoder) WriteInt64(n int64) {
+	switch {
+	case n >= 0:
+		m.WriteUint64(uint64(n))
+	case n >= -32:
+		_ = m.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		_, _ = m.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		m.writeUint16(0xd1, uint16(n))
+	case n >= math.MinInt32:
+		m.writeUint32(0xd2, uint32(n))
+	default:
+		m.writeUint64(0xd3, uint64(n))
+	}
+}
+
+func (m MsgpackEncoder) WriteUint64(n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		_ = m.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		_, _ = m.Write([]byte{0xcc, byte(n)})
+	case n <= math.MaxUint16:
+		m.writeUint16(0xcd, uint16(n))
+	case n <= math.MaxUint32:
+		m.writeUint32(0xce, uint32(n))
+	default:
+		m.writeUint64(0xcf, n)
+	}
+}
+
+func (m MsgpackEncoder) WriteFloat(n float64, bitSize int) {
+	if bitSize == 32 {
+		m.writeUint32(0xca, math.Float32bits(float32(n)))
+		return
+	}
+	m.writeUint64(0xcb, math.Float64bits(n))
+}
+
+func (m MsgpackEncoder) WriteArrayHeader(n int) {
+	switch {
+	case n < 16:
+		_ = m.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		m.writeUint16(0xdc, uint16(n))
+	default:
+		m.writeUint32(0xdd, uint32(n))
+	}
+}
+
+func (m MsgpackEncoder) WriteMapHeader(n int) {
+	switch {
+	case n < 16:
+		_ = m.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		m.writeUint16(0xde, uint16(n))
+	default:
+		m.writeUint32(0xdf, uint32(n))
+	}
+}
+
+func (m MsgpackEncoder) WriteStringHeader(n int) {
+	switch {
+	case n < 32:
+		_ = m.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		_, _ = m.Write([]byte{0xd9, byte(n)})
+	case n <= math.MaxUint16:
+		m.writeUint16(0xda, uint16(n))
+	default:
+		m.writeUint32(0xdb, uint32(n))
+	}
+}
+
+func (m MsgpackEncoder) WriteBinaryHeader(n int) {
+	switch {
+	case n <= math.MaxUint8:
+		_, _ = m.Write([]byte{0xc4, byte(n)})
+	case n <= math.MaxUint16:
+		m.writeUint16(0xc5, uint16(n))
+	default:
+		m.writeUint32(0xc6, uint32(n))
+	}
+}
+
+// WriteStr writes s as a MessagePack string.
+func (m MsgpackEncoder) WriteStr(s string) {
+	m.WriteStringHeader(len(s))
+	_, _ = m.WriteString(s)
+}
+
+// WriteBin writes p as a MessagePack binary.
+func (m MsgpackEncoder) WriteBin(p []byte) {
+	m.WriteBinaryHeader(len(p))
+	_, _ = m.Write(p)
+}
+
+// WriteStrFunc writes the bytes written by fn to the buffer as a MessagePack string,
+// it is used when the length of the string is not known in advance.
+func (m MsgpackEncoder) WriteStrFunc(fn func(b *Buffer)) {
+	// reserve a str 32 header, and fill in the length after the content is written.
+	start := m.Len()
+	m.writeUint32(0xdb, 0)
+	fn(m.Buffer)
+	binary.BigEndian.PutUint32(m.Bytes()[start+1:start+5], uint32(m.Len()-start-5))
+}
+
+// WriteEventTime writes t as the EventTime extension type of the Fluent Forward protocol.
+func (m MsgpackEncoder) WriteEventTime(t time.Time) {
+	var b [10]byte
+	b[0] = 0xd7 // fixext 8
+	b[1] = 0x00 // type 0
+	binary.BigEndian.PutUint32(b[2:6], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[6:10], uint32(t.Nanosecond()))
+	_, _ = m.Write(b[:])
+}
+
+func (m MsgpackEncoder) WriteValue(value any) {
+	switch v := value.(type) {
+	case string:
+		m.WriteStr(v)
+	case []byte:
+		m.WriteBin(v)
+	case error:
+		m.WriteStr(v.Error())
+	case time.Time:
+		m.WriteStrFunc(func(b *Buffer) {
+			b.WriteTime(v, time.RFC3339)
+		})
+	case nil:
+		m.WriteNil()
+	case int:
+		m.WriteInt64(int64(v))
+	case int8:
+		m.WriteInt64(int64(v))
+	case int16:
+		m.WriteInt64(int64(v))
+	case int32:
+		m.WriteInt64(int64(v))
+	case int64:
+		m.WriteInt64(v)
+	case uint:
+		m.WriteUint64(uint64(v))
+	case uint8:
+		m.WriteUint64(uint64(v))
+	case uint16:
+		m.WriteUint64(uint64(v))
+	case uint32:
+		m.WriteUint64(uint64(v))
+	case uint64:
+		m.WriteUint64(v)
+	case float32:
+		m.WriteFloat(float64(v), 32)
+	case float64:
+		m.WriteFloat(v, 64)
+	case bool:
+		m.WriteBool(v)
+	case fmt.Formatter:
+		m.WriteStrFunc(func(b *Buffer) {
+			v.Format(PlainEncoder{Buffer: b}, 'v')
+		})
+	case fmt.Stringer:
+		m.WriteStr(v.String())
+	default:
+		m.WriteStrFunc(func(b *Buffer) {
+			enc := json.NewEncoder(b)
+			enc.SetEscapeHTML(false)
+
+			if err := enc.Encode(value); err != nil {
+				_, _ = b.WriteString("json.Marshal err: " + err.Error())
+				return
+			}
+
+			// drop \n
+			b.DropTail(1)
+		})
+	}
+}
+
+func (m MsgpackEncoder) writeUint16(code byte, n uint16) {
+	var b [3]byte
+	b[0] = code
+	binary.BigEndian.PutUint16(b[1:], n)
+	_, _ = m.Write(b[:])
+}
+
+func (m MsgpackEncoder) writeUint32(code byte, n uint32) {
+	var b [5]byte
+	b[0] = code
+	binary.BigEndian.PutUint32(b[1:], n)
+	_, _ = m.Write(b[:])
+}
+
+func (m MsgpackEncoder) writeUint64(code byte, n uint64) {
+	var b [9]byte
+	b[0] = code
+	binary.BigEndian.PutUint64(b[1:], n)
+	_, _ = m.Write(b[:])
+}
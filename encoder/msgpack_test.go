@@ -0,0 +1,85 @@
+package encoder
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackEncoder_WriteValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  []byte
+	}{
+		{value: nil, want: []byte{0xc0}},
+		{value: true, want: []byte{0xc3}},
+		{value: false, want: []byte{0xc2}},
+		{value: 1, want: []byte{0x01}},
+		{value: 127, want: []byte{0x7f}},
+		{value: 128, want: []byte{0xcc, 0x80}},
+		{value: 256, want: []byte{0xcd, 0x01, 0x00}},
+		{value: 1 << 16, want: []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{value: uint64(1) << 32, want: []byte{0xcf, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{value: -1, want: []byte{0xff}},
+		{value: -32, want: []byte{0xe0}},
+		{value: -33, want: []byte{0xd0, 0xdf}},
+		{value: int16(-200), want: []byte{0xd1, 0xff, 0x38}},
+		{value: int32(-40000), want: []byte{0xd2, 0xff, 0xff, 0x63, 0xc0}},
+		{value: int64(math.MinInt64), want: []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{value: float32(1.5), want: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{value: 1.5, want: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{value: "abc", want: []byte{0xa3, 'a', 'b', 'c'}},
+		{value: []byte("ab"), want: []byte{0xc4, 0x02, 'a', 'b'}},
+		{value: errors.New("e"), want: []byte{0xa1, 'e'}},
+		{value: &testStringer{s: "s"}, want: []byte{0xa1, 's'}},
+		{value: &testFormatter{s: "f"}, want: []byte{0xdb, 0, 0, 0, 1, 'f'}},
+		{value: map[string]int{"a": 1}, want: append([]byte{0xdb, 0, 0, 0, 7}, `{"a":1}`...)},
+		{
+			value: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  append([]byte{0xdb, 0, 0, 0, 20}, "2020-01-01T00:00:00Z"...),
+		},
+	}
+
+	for _, tt := range tests {
+		enc := MsgpackEncoder{Buffer: &Buffer{}}
+		enc.WriteValue(tt.value)
+		if !bytes.Equal(enc.Bytes(), tt.want) {
+			t.Errorf("WriteValue(%v) = % x, want % x", tt.value, enc.Bytes(), tt.want)
+		}
+	}
+}
+
+func TestMsgpackEncoder_Headers(t *testing.T) {
+	enc := MsgpackEncoder{Buffer: &Buffer{}}
+
+	enc.WriteStr(strings.Repeat("a", 32))
+	if !bytes.HasPrefix(enc.Bytes(), []byte{0xd9, 32}) {
+		t.Errorf("str 8 header = % x", enc.Bytes()[:2])
+	}
+
+	enc.Reset()
+	enc.WriteStr(strings.Repeat("a", 256))
+	if !bytes.HasPrefix(enc.Bytes(), []byte{0xda, 0x01, 0x00}) {
+		t.Errorf("str 16 header = % x", enc.Bytes()[:3])
+	}
+
+	enc.Reset()
+	enc.WriteArrayHeader(3)
+	enc.WriteArrayHeader(16)
+	enc.WriteMapHeader(2)
+	enc.WriteMapHeader(1 << 16)
+	want := []byte{0x93, 0xdc, 0x00, 0x10, 0x82, 0xdf, 0x00, 0x01, 0x00, 0x00}
+	if !bytes.Equal(enc.Bytes(), want) {
+		t.Errorf("headers = % x, want % x", enc.Bytes(), want)
+	}
+
+	enc.Reset()
+	enc.WriteEventTime(time.Unix(1, 2))
+	want = []byte{0xd7, 0x00, 0, 0, 0, 1, 0, 0, 0, 2}
+	if !bytes.Equal(enc.Bytes(), want) {
+		t.Errorf("event time = % x, want % x", enc.Bytes(), want)
+	}
+}
@@ -0,0 +1,181 @@
+package olog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// maxErrorChain is the maximum number of the wrapped errors written by the rich error rendering.
+const maxErrorChain = 32
+
+// StackTracer is implemented by the errors carrying the stack of where they were created. The rich error
+// rendering writes the stack of the deepest error in the chain implementing it as the stack of the error.
+type StackTracer interface {
+	// StackTrace returns the program counters of the stack, as returned by runtime.Callers.
+	StackTrace() []uintptr
+}
+
+// richError is the structure of an error written by the rich error rendering.
+type richError struct {
+	err     error
+	chain   []error   // chain is the wrapped errors by errors.Unwrap and errors.Join in depth-first order
+	verbose string    // verbose is the %+v output of the error implementing fmt.Formatter if it differs from Error
+	stack   []uintptr // stack is the stack of the deepest error in the chain implementing StackTracer
+}
+
+// newRichError returns the structure of the error.
+func newRichError(err error) richError {
+	re := richError{err: err}
+	if f, ok := err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", f); verbose != err.Error() {
+			re.verbose = verbose
+		}
+	}
+
+	stackDepth := -1
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		if err == nil || len(re.chain) >= maxErrorChain {
+			return
+		}
+		if depth > 0 {
+			re.chain = append(re.chain, err)
+		}
+		if st, ok := err.(StackTracer); ok && depth > stackDepth {
+			if stack := st.StackTrace(); len(stack) > 0 {
+				re.stack = stack
+				stackDepth = depth
+			}
+		}
+
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range x.Unwrap() {
+				walk(e, depth+1)
+			}
+		default:
+			walk(errors.Unwrap(err), depth+1)
+		}
+	}
+	walk(err, 0)
+
+	return re
+}
+
+// errorType returns the name of the dynamic type of the error.
+func errorType(err error) string {
+	return reflect.TypeOf(err).String()
+}
+
+// writeJSONError writes the error as an object with its message, type, the chain of the wrapped errors,
+// the verbose output and the stack.
+func writeJSONError(enc encoder.JsonEncoder, err error) {
+	re := newRichError(err)
+
+	_, _ = enc.WriteString(`{"message":"`)
+	enc.WriteEscapedString(err.Error())
+	_, _ = enc.WriteString(`","type":"`)
+	enc.WriteEscapedString(errorType(err))
+	enc.WriteQuote()
+
+	if len(re.chain) > 0 {
+		_, _ = enc.WriteString(`,"chain":[`)
+		for i, e := range re.chain {
+			if i > 0 {
+				enc.WriteSeparator()
+			}
+			_, _ = enc.WriteString(`{"message":"`)
+			enc.WriteEscapedString(e.Error())
+			_, _ = enc.WriteString(`","type":"`)
+			enc.WriteEscapedString(errorType(e))
+			_, _ = enc.WriteString(`"}`)
+		}
+		enc.EndArray()
+	}
+
+	if re.verbose != "" {
+		_, _ = enc.WriteString(`,"verbose":"`)
+		enc.WriteEscapedString(re.verbose)
+		enc.WriteQuote()
+	}
+
+	if len(re.stack) > 0 {
+		_, _ = enc.WriteString(`,"stack":[`)
+		frames := runtime.CallersFrames(re.stack)
+		for i := 0; ; i++ {
+			frame, more := frames.Next()
+			if i > 0 {
+				enc.WriteSeparator()
+			}
+			_, _ = enc.WriteString(`{"function":"`)
+			enc.WriteEscapedString(frame.Function)
+			_, _ = enc.WriteString(`","file":"`)
+			enc.WriteEscapedString(frame.File)
+			_, _ = enc.WriteString(`","line":`)
+			enc.WriteInt64(int64(frame.Line))
+			enc.EndObject()
+			if !more {
+				break
+			}
+		}
+		enc.EndArray()
+	}
+
+	enc.EndObject()
+}
+
+// writePlainError writes the message of the error, followed by the chain of the wrapped errors, the verbose
+// output and the stack on the lines starting with nl, the nested lines are further indented by indent.
+func writePlainError(enc encoder.PlainEncoder, err error, nl, indent string) {
+	re := newRichError(err)
+
+	_, _ = enc.WriteString(err.Error())
+
+	if len(re.chain) > 0 {
+		_, _ = enc.WriteString(nl)
+		_, _ = enc.WriteString("chain:")
+		for _, e := range re.chain {
+			_, _ = enc.WriteString(nl)
+			_, _ = enc.WriteString(indent)
+			_, _ = enc.WriteString(errorType(e))
+			_, _ = enc.WriteString(": ")
+			_, _ = enc.WriteString(e.Error())
+		}
+	}
+
+	if re.verbose != "" {
+		_, _ = enc.WriteString(nl)
+		_, _ = enc.WriteString("verbose:")
+		for _, line := range strings.Split(strings.TrimRight(re.verbose, "\n"), "\n") {
+			_, _ = enc.WriteString(nl)
+			_, _ = enc.WriteString(indent)
+			_, _ = enc.WriteString(line)
+		}
+	}
+
+	if len(re.stack) > 0 {
+		_, _ = enc.WriteString(nl)
+		_, _ = enc.WriteString("stack:")
+		frames := runtime.CallersFrames(re.stack)
+		for {
+			frame, more := frames.Next()
+			_, _ = enc.WriteString(nl)
+			_, _ = enc.WriteString(indent)
+			_, _ = enc.WriteString(frame.Function)
+			_, _ = enc.WriteString(nl)
+			_, _ = enc.WriteString(indent)
+			_, _ = enc.WriteString(indent)
+			_, _ = enc.WriteString(frame.File)
+			_ = enc.WriteByte(':')
+			enc.WriteInt64(int64(frame.Line))
+			if !more {
+				break
+			}
+		}
+	}
+}
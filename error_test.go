@@ -0,0 +1,138 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// tracedError is an error carrying the stack of where it was created.
+type tracedError struct {
+	msg   string
+	stack []uintptr
+}
+
+func newTracedError(msg string) error {
+	pc := make([]uintptr, 1)
+	n := runtime.Callers(2, pc)
+	return &tracedError{msg: msg, stack: pc[:n]}
+}
+
+func (e *tracedError) Error() string { return e.msg }
+
+func (e *tracedError) StackTrace() []uintptr { return e.stack }
+
+// verboseError is an error writing the details by %+v.
+type verboseError struct{}
+
+func (verboseError) Error() string { return "verbose" }
+
+func (e verboseError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		_, _ = io.WriteString(s, "verbose\ndetail")
+		return
+	}
+	_, _ = io.WriteString(s, e.Error())
+}
+
+// joinedError is an error wrapping many errors like the ones of errors.Join, which needs go1.20.
+type joinedError []error
+
+func (e joinedError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinedError) Unwrap() []error { return e }
+
+func TestRichErrorJSON(t *testing.T) {
+	root := newTracedError("root")
+	err := fmt.Errorf("top: %w", joinedError{fmt.Errorf("mid: %w", root), verboseError{}})
+
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerRichError(true))
+	logger.Errorw("failed", Field{Key: "err", Value: err}, Field{Key: "plain", Value: errors.New("plain")})
+
+	m := make(map[string]any)
+	if e := json.Unmarshal(buf.Bytes(), &m); e != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), e)
+	}
+
+	got := m["err"].(map[string]any)
+	stack := got["stack"].([]any)
+	delete(got, "stack")
+	want := map[string]any{
+		"message": err.Error(),
+		"type":    "*fmt.wrapError",
+		"chain": []any{
+			map[string]any{"message": "mid: root\nverbose", "type": "olog.joinedError"},
+			map[string]any{"message": "mid: root", "type": "*fmt.wrapError"},
+			map[string]any{"message": "root", "type": "*olog.tracedError"},
+			map[string]any{"message": "verbose", "type": "olog.verboseError"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("err = %v, want %v", got, want)
+	}
+
+	frame := stack[0].(map[string]any)
+	if frame["function"] != "github.com/welllog/olog.TestRichErrorJSON" || !strings.HasSuffix(frame["file"].(string), "error_test.go") {
+		t.Errorf("stack = %v", stack)
+	}
+
+	want = map[string]any{"message": "plain", "type": "*errors.errorString"}
+	if !reflect.DeepEqual(m["plain"], want) {
+		t.Errorf("plain = %v, want %v", m["plain"], want)
+	}
+}
+
+func TestRichErrorPlain(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerColor(false),
+		WithLoggerCaller(false), WithLoggerTimeFormat(""), WithLoggerRichError(true))
+	logger.Errorw("failed", Field{Key: "err", Value: fmt.Errorf("top: %w", verboseError{})})
+
+	want := "\terror\tfailed\terr=top: verbose\n\tchain:\n\t\tolog.verboseError: verbose\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger.Errorw("failed", Field{Key: "err", Value: verboseError{}})
+	want = "\terror\tfailed\terr=verbose\n\tverbose:\n\t\tverbose\n\t\tdetail\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	// the errors are written by Error without the rich error rendering.
+	buf.Reset()
+	logger = NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(PLAIN), WithLoggerColor(false),
+		WithLoggerCaller(false), WithLoggerTimeFormat(""))
+	logger.Errorw("failed", Field{Key: "err", Value: fmt.Errorf("top: %w", verboseError{})})
+	want = "\terror\tfailed\terr=top: verbose\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRichErrorConsole(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncode(CONSOLE), WithLoggerColor(false),
+		WithLoggerCaller(false), WithLoggerRichError(true))
+	logger.Errorw("failed", Field{Key: "err", Value: newTracedError("root")})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 || lines[1] != "    err: root" || lines[2] != "        stack:" ||
+		lines[3] != "            github.com/welllog/olog.TestRichErrorConsole" {
+		t.Errorf("output = %q", buf.String())
+	}
+}
@@ -0,0 +1,387 @@
+package olog
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/welllog/olog/encoder"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// FluentWriter is a Writer that sends log records to fluentd or fluent-bit with the Fluent Forward protocol.
+// Records are batched by tag and sent in PackedForward mode, and the batches are retried until they are
+// acknowledged by the server if ack is enabled, so records may be delivered more than once.
+// It must be used together with its Encode method as the EncodeFunc of the logger:
+//
+//	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode))
+type FluentWriter struct {
+	network   string
+	addr      string
+	tagPrefix string
+	ack       bool
+	timeout   time.Duration
+	batchOpts []batch.Option
+
+	conn  net.Conn // conn is only used by the single batch worker
+	batch *batch.Writer
+}
+
+// FluentOption is a functional option type for configuring a FluentWriter.
+type FluentOption func(*FluentWriter)
+
+// WithFluentTagPrefix sets the prefix of the tags, which are the prefix, the App and the level tag joined by dots.
+// "olog" is used by default.
+func WithFluentTagPrefix(prefix string) FluentOption {
+	return func(f *FluentWriter) {
+		f.tagPrefix = prefix
+	}
+}
+
+// WithFluentAck sets whether to require the server to acknowledge the received chunks.
+func WithFluentAck(enable bool) FluentOption {
+	return func(f *FluentWriter) {
+		f.ack = enable
+	}
+}
+
+// WithFluentTimeout sets the timeout of dialing, writing and waiting for the ack, 5 seconds is used by default.
+func WithFluentTimeout(timeout time.Duration) FluentOption {
+	return func(f *FluentWriter) {
+		f.timeout = timeout
+	}
+}
+
+// WithFluentBatch sets the batching options, the batches are always sent by one worker.
+func WithFluentBatch(opts ...batch.Option) FluentOption {
+	return func(f *FluentWriter) {
+		f.batchOpts = append(f.batchOpts, opts...)
+	}
+}
+
+// NewFluentWriter creates a new FluentWriter sending records to the forward input at the network address,
+// such as ("tcp", "localhost:24224") or ("unix", "/var/run/fluent.sock").
+func NewFluentWriter(network, addr string, opts ...FluentOption) *FluentWriter {
+	f := &FluentWriter{
+		network:   network,
+		addr:      addr,
+		tagPrefix: "olog",
+		timeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	f.batch = batch.New(f.send, append(f.batchOpts, batch.WithWorkers(1))...)
+	return f
+}
+
+// Write copies the record encoded by Encode into the current batch. The records not encoded by Encode are
+// passed to the failure callback and an error is returned.
+func (f *FluentWriter) Write(level Level, p []byte) (n int, err error) {
+	if _, _, ok := readFluentRecord(p); !ok {
+		return 0, f.batch.Reject(p, ErrNotEncoded)
+	}
+	f.batch.Add(p)
+	return len(p), nil
+}
+
+// Flush sends the current batch and waits until all the batches are sent.
+func (f *FluentWriter) Flush() {
+	f.batch.Flush()
+}
+
+// Close sends the remaining batches and closes the connection.
+func (f *FluentWriter) Close() error {
+	err := f.batch.Close()
+	if f.conn != nil {
+		_ = f.conn.Close()
+		f.conn = nil
+	}
+	return err
+}
+
+// Encode encodes the Record as the tag followed by a Forward entry, which is an array of the EventTime and
+// the record map, to the buffer.
+func (f *FluentWriter) Encode(r Record, buf *encoder.Buffer) {
+	enc := encoder.MsgpackEncoder{Buffer: buf}
+
+	enc.WriteStrFunc(func(b *encoder.Buffer) {
+		_, _ = b.WriteString(f.tagPrefix)
+		if r.App != "" {
+			_ = b.WriteByte('.')
+			_, _ = b.WriteString(r.App)
+		}
+		_ = b.WriteByte('.')
+		_, _ = b.WriteString(r.LevelTag)
+	})
+
+	enc.WriteArrayHeader(2)
+	enc.WriteEventTime(r.Time)
+
+	// reserve a map 32 header, and fill in the number of entries at the end.
+	start := buf.Len()
+	_, _ = buf.Write([]byte{0xdf, 0, 0, 0, 0})
+	size := 2
+
+	enc.WriteStr(fieldLevel)
+	enc.WriteStr(r.LevelTag)
+
+	if r.App != "" {
+		enc.WriteStr(fieldApp)
+		enc.WriteStr(r.App)
+		size++
+	}
+
+	if r.Name != "" {
+		enc.WriteStr(fieldLogger)
+		enc.WriteStr(r.Name)
+		size++
+	}
+
+	frame, frames, more := r.FirstFrame()
+
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		enc.WriteStr(fieldCaller)
+		enc.WriteStrFunc(func(b *encoder.Buffer) {
+			_, _ = b.WriteString(file)
+			_ = b.WriteByte(':')
+			b.WriteInt64(int64(frame.Line))
+		})
+		size++
+	}
+
+	enc.WriteStr(fieldContent)
+	enc.WriteStrFunc(func(b *encoder.Buffer) {
+		_, _ = encoder.EPrintf(encoder.PlainEncoder{Buffer: b}, r.MsgOrFormat, r.MsgArgs...)
+	})
+
+	fields := flattenGroups(r.Fields)
+	defer putFields(fields)
+	dedup := newFieldDedup(r.Duplicates, *fields, filterField)
+	for i, field := range *fields {
+		if key, ok := dedup.key(i); ok {
+			enc.WriteStr(key)
+			enc.WriteValue(field.Value)
+			size++
+		}
+	}
+
+	if r.Stack.IsOpen() {
+		enc.WriteStr(fieldStack)
+		enc.WriteStrFunc(func(b *encoder.Buffer) {
+			WriteStackText(b, r.StackOpts, frame, frames, more, true, false)
+		})
+		size++
+	}
+
+	binary.BigEndian.PutUint32(buf.Bytes()[start+1:start+5], uint32(size))
+}
+
+// fluentChunk is the entries of a tag in a batch.
+type fluentChunk struct {
+	tag     []byte
+	entries []byte
+	count   int
+	records [][]byte // records is the records of the entries, which are retried if the chunk is not acked
+}
+
+// send sends the batch as a PackedForward message per tag.
+func (f *FluentWriter) send(records [][]byte) error {
+	var chunks []*fluentChunk
+	for _, record := range records {
+		tag, entry, ok := readFluentRecord(record)
+		if !ok {
+			// the records not encoded by Encode are rejected by Write.
+			continue
+		}
+
+		var chunk *fluentChunk
+		for _, c := range chunks {
+			if string(c.tag) == string(tag) {
+				chunk = c
+				break
+			}
+		}
+		if chunk == nil {
+			chunk = &fluentChunk{tag: tag}
+			chunks = append(chunks, chunk)
+		}
+		chunk.entries = append(chunk.entries, entry...)
+		chunk.count++
+		chunk.records = append(chunk.records, record)
+	}
+
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.addr, f.timeout)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+
+	buf := getBuf()
+	defer putBuf(buf)
+
+	for i, chunk := range chunks {
+		buf.Reset()
+		id := f.writeMessage(buf, chunk)
+
+		if err := f.roundTrip(buf.Bytes(), id); err != nil {
+			_ = f.conn.Close()
+			f.conn = nil
+
+			// the chunks acked are not sent again by the retries.
+			var remaining [][]byte
+			for _, c := range chunks[i:] {
+				remaining = append(remaining, c.records...)
+			}
+			return batch.Partial(remaining, err)
+		}
+	}
+	return nil
+}
+
+// writeMessage writes the PackedForward message of the chunk to the buffer, and returns the chunk id.
+func (f *FluentWriter) writeMessage(buf *encoder.Buffer, chunk *fluentChunk) string {
+	enc := encoder.MsgpackEncoder{Buffer: buf}
+	enc.WriteArrayHeader(3)
+	enc.WriteStringHeader(len(chunk.tag))
+	_, _ = enc.Write(chunk.tag)
+	enc.WriteBin(chunk.entries)
+
+	var id string
+	if f.ack {
+		enc.WriteMapHeader(2)
+		var b [16]byte
+		_, _ = rand.Read(b[:])
+		id = base64.StdEncoding.EncodeToString(b[:])
+		enc.WriteStr("chunk")
+		enc.WriteStr(id)
+	} else {
+		enc.WriteMapHeader(1)
+	}
+	enc.WriteStr("size")
+	enc.WriteInt64(int64(chunk.count))
+	return id
+}
+
+// roundTrip writes the message, and waits for the ack of the chunk id if ack is enabled.
+func (f *FluentWriter) roundTrip(msg []byte, id string) error {
+	_ = f.conn.SetWriteDeadline(time.Now().Add(f.timeout))
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+
+	if !f.ack {
+		return nil
+	}
+
+	_ = f.conn.SetReadDeadline(time.Now().Add(f.timeout))
+	ack, err := readFluentAck(f.conn)
+	if err != nil {
+		return err
+	}
+	if ack != id {
+		return fmt.Errorf("olog: fluent ack %q mismatches chunk %q", ack, id)
+	}
+	return nil
+}
+
+// readFluentAck reads the ack response, which is a map like {"ack": "<chunk id>"}.
+func readFluentAck(r io.Reader) (string, error) {
+	var (
+		b   [256]byte
+		n   int
+		err error
+	)
+	for {
+		var m int
+		m, err = r.Read(b[n:])
+		n += m
+
+		p := b[:n]
+		if size, rest, ok := readMsgpackMapHeader(p); ok {
+			var ack []byte
+			for i := 0; i < size && ok; i++ {
+				var key, value []byte
+				if key, rest, ok = readMsgpackStr(rest); ok {
+					if value, rest, ok = readMsgpackStr(rest); ok && string(key) == "ack" {
+						ack = value
+					}
+				}
+			}
+			if ok {
+				if ack == nil {
+					return "", errors.New("olog: fluent response has no ack")
+				}
+				return string(ack), nil
+			}
+		} else if n > 0 {
+			return "", errors.New("olog: invalid fluent ack response")
+		}
+
+		if err != nil {
+			return "", err
+		}
+		if n == len(b) {
+			return "", errors.New("olog: fluent ack response is too large")
+		}
+	}
+}
+
+// readMsgpackMapHeader reads a MessagePack map header, ok is false if p is not a complete map header.
+func readMsgpackMapHeader(p []byte) (size int, rest []byte, ok bool) {
+	if len(p) == 0 {
+		return 0, p, false
+	}
+	switch c := p[0]; {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), p[1:], true
+	case c == 0xde && len(p) >= 3:
+		return int(binary.BigEndian.Uint16(p[1:3])), p[3:], true
+	case c == 0xdf && len(p) >= 5:
+		return int(binary.BigEndian.Uint32(p[1:5])), p[5:], true
+	}
+	return 0, p, false
+}
+
+// readFluentRecord reads the tag and the Forward entry of the record encoded by Encode, ok is false if the
+// record is not encoded by Encode.
+func readFluentRecord(p []byte) (tag, entry []byte, ok bool) {
+	tag, entry, ok = readMsgpackStr(p)
+	// the entry is an array of the EventTime and the record map.
+	return tag, entry, ok && len(entry) > 0 && entry[0] == 0x92
+}
+
+// readMsgpackStr reads a MessagePack string, ok is false if p does not start with a complete string.
+func readMsgpackStr(p []byte) (s, rest []byte, ok bool) {
+	if len(p) == 0 {
+		return nil, p, false
+	}
+
+	var size, head int
+	switch c := p[0]; {
+	case c&0xe0 == 0xa0:
+		size, head = int(c&0x1f), 1
+	case c == 0xd9 && len(p) >= 2:
+		size, head = int(p[1]), 2
+	case c == 0xda && len(p) >= 3:
+		size, head = int(binary.BigEndian.Uint16(p[1:3])), 3
+	case c == 0xdb && len(p) >= 5:
+		size, head = int(binary.BigEndian.Uint32(p[1:5])), 5
+	default:
+		return nil, p, false
+	}
+
+	if len(p) < head+size {
+		return nil, p, false
+	}
+	return p[head : head+size], p[head+size:], true
+}
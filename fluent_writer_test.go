@@ -0,0 +1,400 @@
+package olog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog/encoder"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// decodeMsgpack decodes a MessagePack value from the reader, it supports the types used by the fluent writer.
+func decodeMsgpack(r *bufio.Reader) (any, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	readN := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	readUint := func(n int) (uint64, error) {
+		b, err := readN(n)
+		if err != nil {
+			return 0, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		return v, nil
+	}
+	readArray := func(n int) (any, error) {
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	readMap := func(n int) (any, error) {
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			k, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[fmt.Sprint(k)], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return readMap(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return readArray(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		b, err := readN(int(c & 0x1f))
+		return string(b), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readN(int(n))
+	case 0xca:
+		v, err := readUint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := readUint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := readUint(1 << (c - 0xcc))
+		return int64(v), err
+	case 0xd0:
+		v, err := readUint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := readUint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := readUint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := readUint(8)
+		return int64(v), err
+	case 0xd7:
+		b, err := readN(9)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(b[1:5])), int64(binary.BigEndian.Uint32(b[5:9]))), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		b, err := readN(int(n))
+		return string(b), err
+	case 0xdc, 0xdd:
+		n, err := readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readArray(int(n))
+	case 0xde, 0xdf:
+		n, err := readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMap(int(n))
+	}
+	return nil, fmt.Errorf("unsupported msgpack code 0x%x", c)
+}
+
+// fluentEvent is an event received by the fake forward server.
+type fluentEvent struct {
+	tag    string
+	time   time.Time
+	record map[string]any
+}
+
+// fakeForwardServer is an in-process forward server, it acks the chunks if required,
+// and can ignore the first chunks to test the retries.
+type fakeForwardServer struct {
+	t      *testing.T
+	ln     net.Listener
+	mu     sync.Mutex
+	ignore int
+	chunks int
+	// ignoreTag is the tag of which the first chunk is ignored.
+	ignoreTag string
+	events    chan fluentEvent
+}
+
+func newFakeForwardServer(t *testing.T, ignore int) *fakeForwardServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeForwardServer{t: t, ln: ln, ignore: ignore, events: make(chan fluentEvent, 100)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeForwardServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return
+		}
+		msg := v.([]any)
+		tag := msg[0].(string)
+		option := msg[2].(map[string]any)
+
+		entries := bufio.NewReader(bytes.NewReader(msg[1].([]byte)))
+		var events []fluentEvent
+		for {
+			e, err := decodeMsgpack(entries)
+			if err != nil {
+				break
+			}
+			entry := e.([]any)
+			events = append(events, fluentEvent{tag: tag, time: entry[0].(time.Time), record: entry[1].(map[string]any)})
+		}
+		if int(option["size"].(int64)) != len(events) {
+			s.t.Errorf("size = %v, want %d", option["size"], len(events))
+		}
+
+		s.mu.Lock()
+		s.chunks++
+		ignore := s.ignore > 0 || tag == s.ignoreTag
+		if s.ignore > 0 {
+			s.ignore--
+		}
+		if tag == s.ignoreTag {
+			s.ignoreTag = ""
+		}
+		s.mu.Unlock()
+
+		if ignore {
+			// drop the connection without ack, the client should retry.
+			return
+		}
+
+		for _, e := range events {
+			s.events <- e
+		}
+
+		if chunk, ok := option["chunk"]; ok {
+			enc := encoder.MsgpackEncoder{Buffer: encoder.NewBuffer(nil)}
+			enc.WriteMapHeader(1)
+			enc.WriteStr("ack")
+			enc.WriteStr(chunk.(string))
+			_, _ = conn.Write(enc.Bytes())
+		}
+	}
+}
+
+func (s *fakeForwardServer) next() fluentEvent {
+	select {
+	case e := <-s.events:
+		return e
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timeout waiting for event")
+	}
+	return fluentEvent{}
+}
+
+func TestFluentWriter(t *testing.T) {
+	server := newFakeForwardServer(t, 1)
+	defer server.ln.Close()
+
+	w := NewFluentWriter("tcp", server.ln.Addr().String(),
+		WithFluentAck(true),
+		WithFluentTimeout(time.Second),
+		WithFluentBatch(batch.WithSize(10, 1<<20), batch.WithAge(time.Hour), batch.WithRetry(3, time.Millisecond, 10*time.Millisecond)),
+	)
+	defer w.Close()
+
+	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode), WithLoggerAppName("svc"))
+	now := time.Now()
+	logger.Infow("hello", Field{Key: "name", Value: "bob"}, Field{Key: "age", Value: 18})
+	logger.Error("failed")
+	logger.Infof("hello %s", "alice")
+	w.Flush()
+
+	e := server.next()
+	if e.tag != "olog.svc.info" {
+		t.Errorf("tag = %s, want olog.svc.info", e.tag)
+	}
+	if e.time.Before(now.Truncate(time.Second)) {
+		t.Errorf("time = %s, want after %s", e.time, now)
+	}
+	want := map[string]any{
+		"level":   "info",
+		"app":     "svc",
+		"caller":  "olog/fluent_writer_test.go:259",
+		"content": "hello",
+		"name":    "bob",
+		"age":     int64(18),
+	}
+	if fmt.Sprint(e.record) != fmt.Sprint(want) {
+		t.Errorf("record = %v, want %v", e.record, want)
+	}
+
+	// records are grouped by tag, in order of the first record of each tag.
+	e = server.next()
+	if e.tag != "olog.svc.info" || e.record["content"] != "hello alice" {
+		t.Errorf("event = %+v", e)
+	}
+	e = server.next()
+	if e.tag != "olog.svc.error" || e.record["content"] != "failed" {
+		t.Errorf("event = %+v", e)
+	}
+
+	server.mu.Lock()
+	chunks := server.chunks
+	server.mu.Unlock()
+	if chunks != 3 {
+		t.Errorf("chunks = %d, want 3", chunks)
+	}
+}
+
+func TestFluentWriterRetryAcked(t *testing.T) {
+	server := newFakeForwardServer(t, 0)
+	server.ignoreTag = "olog.svc.error"
+	defer server.ln.Close()
+
+	w := NewFluentWriter("tcp", server.ln.Addr().String(),
+		WithFluentAck(true),
+		WithFluentBatch(batch.WithSize(10, 1<<20), batch.WithAge(time.Hour), batch.WithRetry(3, time.Millisecond, time.Millisecond)),
+	)
+	defer w.Close()
+
+	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode), WithLoggerAppName("svc"), WithLoggerCaller(false))
+	logger.Info("acked")
+	logger.Error("retried")
+	w.Flush()
+
+	// the acked chunk of the batch is not sent again by the retry.
+	if e := server.next(); e.record["content"] != "acked" {
+		t.Errorf("event = %+v", e)
+	}
+	if e := server.next(); e.record["content"] != "retried" {
+		t.Errorf("event = %+v", e)
+	}
+	select {
+	case e := <-server.events:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	server.mu.Lock()
+	chunks := server.chunks
+	server.mu.Unlock()
+	if chunks != 3 {
+		t.Errorf("chunks = %d, want 3", chunks)
+	}
+}
+
+func TestFluentWriterFailure(t *testing.T) {
+	server := newFakeForwardServer(t, 100)
+	defer server.ln.Close()
+
+	failed := make(chan int, 1)
+	w := NewFluentWriter("tcp", server.ln.Addr().String(),
+		WithFluentAck(true),
+		WithFluentBatch(
+			batch.WithRetry(2, time.Millisecond, time.Millisecond),
+			batch.WithFailure(func(records [][]byte, err error) {
+				failed <- len(records)
+			}),
+		),
+	)
+	defer w.Close()
+
+	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode))
+	logger.Info("hello")
+	w.Flush()
+
+	select {
+	case n := <-failed:
+		if n != 1 {
+			t.Errorf("failed records = %d, want 1", n)
+		}
+	default:
+		t.Fatal("failure callback is not called")
+	}
+
+	server.mu.Lock()
+	chunks := server.chunks
+	server.mu.Unlock()
+	if chunks != 3 {
+		t.Errorf("chunks = %d, want 3", chunks)
+	}
+}
+
+func TestFluentWriterNotEncoded(t *testing.T) {
+	var failed []error
+	w := NewFluentWriter("tcp", "127.0.0.1:1", WithFluentBatch(batch.WithFailure(func(records [][]byte, err error) {
+		failed = append(failed, err)
+	})))
+	defer w.Close()
+
+	// the logger without the Encode of the writer as the EncodeFunc.
+	for _, encode := range []EncodeType{JSON, PLAIN} {
+		logger := NewLogger(WithLoggerWriter(w), WithLoggerEncode(encode))
+		logger.Info("hello")
+	}
+	if _, err := w.Write(INFO, []byte("hello\n")); err != ErrNotEncoded {
+		t.Errorf("Write error = %v, want %v", err, ErrNotEncoded)
+	}
+	w.Flush()
+
+	if len(failed) != 3 {
+		t.Fatalf("failed records = %d, want 3", len(failed))
+	}
+	for _, err := range failed {
+		if err != ErrNotEncoded {
+			t.Errorf("failure error = %v, want %v", err, ErrNotEncoded)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package olog
+
+import (
+	"os"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// gelfHost is the host of the GELF messages.
+var gelfHost = func() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "unknown"
+	}
+	return EscapedString(host)
+}()
+
+// GelfEncode encodes the Record as a GELF 1.1 message to the buffer. The level is mapped to the syslog severity,
+// the stack to full_message, the caller to _file and _line, and the fields to additional fields prefixed with
+// an underscore. It can be used as the EncodeFunc of the logger together with the Writer of
+// the github.com/welllog/olog/writer/gelf package.
+func GelfEncode(r Record, buf *encoder.Buffer) {
+	enc := encoder.JsonEncoder{Buffer: buf}
+
+	_, _ = enc.WriteString(`{"version":"1.1","host":"`)
+	_, _ = enc.WriteString(gelfHost)
+	_, _ = enc.WriteString(`","short_message":"`)
+	start := buf.Len()
+	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	if buf.Len() == start {
+		// the short_message must not be empty, or Graylog rejects the message.
+		_ = enc.WriteByte('-')
+	}
+	_, _ = enc.WriteString(`","timestamp":`)
+	enc.WriteInt64(r.Time.Unix())
+	_ = enc.WriteByte('.')
+	usec := r.Time.Nanosecond() / 1000
+	for div := 100000; div > 1 && usec < div; div /= 10 {
+		_ = enc.WriteByte('0')
+	}
+	enc.WriteInt64(int64(usec))
+	_, _ = enc.WriteString(`,"level":`)
+	enc.WriteInt64(int64(syslogSeverity(r.Level)))
+	_, _ = enc.WriteString(`,"_level":"`)
+	_, _ = enc.WriteString(r.LevelTag)
+	enc.WriteQuote()
+
+	if r.App != "" {
+		_, _ = enc.WriteString(`,"_app":"`)
+		_, _ = enc.WriteString(r.App)
+		enc.WriteQuote()
+	}
+
+	if r.Name != "" {
+		_, _ = enc.WriteString(`,"_logger":"`)
+		_, _ = enc.WriteString(r.Name)
+		enc.WriteQuote()
+	}
+
+	frame, frames, more := r.FirstFrame()
+
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		_, _ = enc.WriteString(`,"_file":"`)
+		enc.WriteEscapedString(file)
+		_, _ = enc.WriteString(`","_line":`)
+		enc.WriteInt64(int64(frame.Line))
+	}
+
+	fields := flattenGroups(r.Fields)
+	defer putFields(fields)
+	dedup := newFieldDedup(r.Duplicates, *fields, filterField)
+	for i, field := range *fields {
+		if key, ok := dedup.key(i); ok {
+			_, _ = enc.WriteString(`,"_`)
+			writeGelfFieldName(enc, key)
+			_, _ = enc.WriteString(`":`)
+			enc.WriteValue(field.Value)
+		}
+	}
+
+	if r.Stack.IsOpen() && frame.PC != 0 {
+		_, _ = enc.WriteString(`,"full_message":"`)
+		WriteStackText(buf, r.StackOpts, frame, frames, more, false, true)
+		enc.WriteQuote()
+	}
+
+	_, _ = enc.WriteString("}\n")
+}
+
+// writeGelfFieldName writes the field name, the characters not allowed by GELF are replaced by underscores,
+// and "id" is written as "_id" because "_id" is reserved.
+func writeGelfFieldName(enc encoder.JsonEncoder, key string) {
+	if key == "id" {
+		_, _ = enc.WriteString("_id")
+		return
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' || c == '-' {
+			_ = enc.WriteByte(c)
+		} else {
+			_ = enc.WriteByte('_')
+		}
+	}
+}
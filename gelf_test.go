@@ -0,0 +1,69 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGelfEncode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncodeFunc(GelfEncode), WithLoggerAppName("svc"))
+	logger.Log(Record{Level: ERROR, Stack: Enable, StackSize: 1, MsgOrFormat: "hello %s", MsgArgs: []any{"world"},
+		Fields: []Field{{Key: "id", Value: 1}, {Key: "user name", Value: "bob"}, {Key: "level", Value: "x"}}})
+
+	if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) {
+		t.Fatalf("message does not end with newline: %s", buf.Bytes())
+	}
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+
+	want := map[string]any{
+		"version":       "1.1",
+		"host":          gelfHost,
+		"short_message": "hello world",
+		"level":         float64(3),
+		"_level":        "error",
+		"_app":          "svc",
+		"_file":         "olog/gelf_test.go",
+		"__id":          float64(1),
+		"_user_name":    "bob",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %v, want %v", k, m[k], v)
+		}
+	}
+	if ts, _ := m["timestamp"].(float64); math.Abs(ts-float64(time.Now().UnixNano())/1e9) > 5 {
+		t.Errorf("timestamp = %v", m["timestamp"])
+	}
+	if _, ok := m["_line"].(float64); !ok {
+		t.Errorf("_line = %v, want number", m["_line"])
+	}
+	if s, _ := m["full_message"].(string); !strings.HasPrefix(s, "github.com/welllog/olog.TestGelfEncode\n\t") {
+		t.Errorf("full_message = %q", s)
+	}
+	if len(m) != len(want)+3 {
+		t.Errorf("message has unexpected fields: %v", m)
+	}
+}
+
+func TestGelfEncodeEmptyMessage(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerEncodeFunc(GelfEncode), WithLoggerCaller(false))
+	logger.Info("")
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+	if m["short_message"] != "-" {
+		t.Errorf("short_message = %q, want -", m["short_message"])
+	}
+}
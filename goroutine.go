@@ -0,0 +1,222 @@
+package olog
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGoroutineDump is the maximum size of the dump of all the goroutines captured by DumpGoroutines.
+const maxGoroutineDump = 64 << 20
+
+// Goroutine is a goroutine parsed from the dump of runtime.Stack.
+type Goroutine struct {
+	ID     uint64           // ID is the id of the goroutine, or the lowest id of the grouped goroutines
+	State  string           // State is the state of the goroutine, such as "running" or "chan receive"
+	Wait   time.Duration    // Wait is the duration the goroutine has been blocked, with the precision of minutes
+	Locked bool             // Locked reports whether the goroutine is locked to the thread
+	Count  int              // Count is the number of the grouped goroutines with identical stacks
+	Frames []GoroutineFrame // Frames is the frames of the stack, the last one may be the creator of the goroutine
+}
+
+// GoroutineFrame is a frame of the stack of a Goroutine.
+type GoroutineFrame struct {
+	Function string // Function is the function of the frame, prefixed by "created by " for the creator
+	File     string
+	Line     int
+}
+
+// DumpGoroutines logs all the goroutines with the level and the message, one record for each goroutine with
+// the id, state, wait duration, locked flag and frames as fields, the frames are written as an array of {function, file,
+// line} objects on JSON encoding. It does nothing if the level is not enabled.
+func DumpGoroutines(l Logger, level Level, msg string) {
+	if l.IsEnabled(level) {
+		logGoroutines(l, level, msg, ParseGoroutines(goroutineDump()))
+	}
+}
+
+// DumpGoroutineGroups is like DumpGoroutines, but the goroutines with the same state, locked flag and frames
+// are grouped
+// into one record with their count, like the debug=1 output of the goroutine profile of pprof. The groups
+// are logged from the largest.
+func DumpGoroutineGroups(l Logger, level Level, msg string) {
+	if l.IsEnabled(level) {
+		logGoroutines(l, level, msg, GroupGoroutines(ParseGoroutines(goroutineDump())))
+	}
+}
+
+// logGoroutines logs the goroutines as the records reporting the caller of the caller.
+func logGoroutines(l Logger, level Level, msg string, gs []Goroutine) {
+	for _, g := range gs {
+		fields := make([]Field, 0, 6)
+		fields = append(fields, Field{Key: "goroutine", Value: g.ID}, Field{Key: "state", Value: g.State})
+		if g.Wait > 0 {
+			fields = append(fields, Field{Key: "wait", Value: g.Wait.String()})
+		}
+		if g.Locked {
+			fields = append(fields, Field{Key: "locked", Value: true})
+		}
+		if g.Count > 1 {
+			fields = append(fields, Field{Key: "count", Value: g.Count})
+		}
+		fields = append(fields, Field{Key: "frames", Value: g.Frames})
+
+		l.Log(Record{
+			Level:       level,
+			CallerSkip:  2,
+			MsgOrFormat: msg,
+			Fields:      fields,
+		})
+	}
+}
+
+// goroutineDump returns the dump of all the goroutines by runtime.Stack, the buffer is grown until the dump
+// fits or reaches maxGoroutineDump.
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDump {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// ParseGoroutines parses the dump of the goroutines written by runtime.Stack or panics.
+func ParseGoroutines(dump []byte) []Goroutine {
+	var (
+		gs []Goroutine
+		g  *Goroutine
+	)
+	for _, line := range strings.Split(string(dump), "\n") {
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			gs = append(gs, parseGoroutineHeader(line))
+			g = &gs[len(gs)-1]
+		case g == nil || line == "":
+			g = nil
+		case line[0] == '\t':
+			if n := len(g.Frames); n > 0 {
+				g.Frames[n-1].File, g.Frames[n-1].Line = parseGoroutineFile(line[1:])
+			}
+		case strings.HasPrefix(line, "..."):
+			// the frames elided by the runtime.
+		default:
+			g.Frames = append(g.Frames, GoroutineFrame{Function: parseGoroutineFunction(line)})
+		}
+	}
+	return gs
+}
+
+// parseGoroutineHeader parses the header line such as "goroutine 5 [chan receive, 3 minutes, locked to thread]:".
+func parseGoroutineHeader(line string) Goroutine {
+	g := Goroutine{Count: 1}
+	line = strings.TrimPrefix(line, "goroutine ")
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		g.ID, _ = strconv.ParseUint(line[:i], 10, 64)
+		line = line[i+1:]
+	}
+
+	start, end := strings.IndexByte(line, '['), strings.LastIndexByte(line, ']')
+	if start < 0 || end < start {
+		return g
+	}
+	for i, part := range strings.Split(line[start+1:end], ", ") {
+		switch {
+		case i == 0:
+			g.State = part
+		case part == "locked to thread":
+			g.Locked = true
+		case strings.HasSuffix(part, " minutes") || strings.HasSuffix(part, " minute"):
+			if n, err := strconv.Atoi(part[:strings.IndexByte(part, ' ')]); err == nil {
+				g.Wait = time.Duration(n) * time.Minute
+			}
+		}
+	}
+	return g
+}
+
+// parseGoroutineFunction returns the function of the line such as "main.(*T).run(0xc000010000, 0x1)"
+// without the arguments, or the creator of the line such as "created by main.main in goroutine 1".
+func parseGoroutineFunction(line string) string {
+	if strings.HasPrefix(line, "created by ") {
+		if i := strings.Index(line, " in goroutine "); i > 0 {
+			return line[:i]
+		}
+		return line
+	}
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndexByte(line, '('); i > 0 {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseGoroutineFile returns the file and line of the line such as "/app/main.go:12 +0x1d".
+func parseGoroutineFile(line string) (string, int) {
+	if i := strings.LastIndex(line, " +0x"); i > 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return line, 0
+	}
+	n, _ := strconv.Atoi(line[i+1:])
+	return line[:i], n
+}
+
+// GroupGoroutines groups the goroutines with the same state, locked flag and frames, the groups are sorted by the count
+// in descending order and then by the id.
+func GroupGoroutines(gs []Goroutine) []Goroutine {
+	var groups []Goroutine
+	index := make(map[string]int, len(gs))
+	for _, g := range gs {
+		key := g.groupKey()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(groups)
+			groups = append(groups, g)
+			continue
+		}
+
+		group := &groups[i]
+		group.Count += g.Count
+		if g.ID < group.ID {
+			group.ID = g.ID
+		}
+		if g.Wait > group.Wait {
+			group.Wait = g.Wait
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
+
+// groupKey returns the key of the state, locked flag and frames of the goroutine, the elements are separated by NUL which
+// appears in neither the functions nor the files.
+func (g Goroutine) groupKey() string {
+	var b strings.Builder
+	b.WriteString(g.State)
+	if g.Locked {
+		b.WriteString(" locked")
+	}
+	for _, f := range g.Frames {
+		b.WriteByte(0)
+		b.WriteString(f.Function)
+		b.WriteByte(0)
+		b.WriteString(f.File)
+		b.WriteByte(0)
+		b.WriteString(strconv.Itoa(f.Line))
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const goroutineDumpSample = `goroutine 1 [running]:
+main.main()
+	/app/main.go:12 +0x1d
+
+goroutine 7 [chan receive, 3 minutes, locked to thread]:
+main.(*worker).run(0xc000010000, 0x1)
+	/app/worker.go:30 +0x45
+...additional frames elided...
+created by main.start in goroutine 1
+	/app/main.go:20 +0x65
+
+goroutine 6 [chan receive, 5 minutes]:
+main.(*worker).run(0xc000010008, 0x2)
+	/app/worker.go:30 +0x45
+...additional frames elided...
+created by main.start in goroutine 1
+	/app/main.go:20 +0x65
+
+goroutine 8 [chan receive, 1 minute]:
+main.(*worker).run(0xc000010010, 0x3)
+	/app/worker.go:30 +0x45
+...additional frames elided...
+created by main.start in goroutine 1
+	/app/main.go:20 +0x65
+`
+
+func TestParseGoroutines(t *testing.T) {
+	worker := []GoroutineFrame{
+		{Function: "main.(*worker).run", File: "/app/worker.go", Line: 30},
+		{Function: "created by main.start", File: "/app/main.go", Line: 20},
+	}
+	want := []Goroutine{
+		{ID: 1, State: "running", Count: 1, Frames: []GoroutineFrame{{Function: "main.main", File: "/app/main.go", Line: 12}}},
+		{ID: 7, State: "chan receive", Wait: 3 * time.Minute, Locked: true, Count: 1, Frames: worker},
+		{ID: 6, State: "chan receive", Wait: 5 * time.Minute, Count: 1, Frames: worker},
+		{ID: 8, State: "chan receive", Wait: time.Minute, Count: 1, Frames: worker},
+	}
+	gs := ParseGoroutines([]byte(goroutineDumpSample))
+	if !reflect.DeepEqual(gs, want) {
+		t.Fatalf("ParseGoroutines = %+v, want %+v", gs, want)
+	}
+
+	want = []Goroutine{
+		{ID: 6, State: "chan receive", Wait: 5 * time.Minute, Count: 2, Frames: worker},
+		{ID: 1, State: "running", Count: 1, Frames: []GoroutineFrame{{Function: "main.main", File: "/app/main.go", Line: 12}}},
+		{ID: 7, State: "chan receive", Wait: 3 * time.Minute, Locked: true, Count: 1, Frames: worker},
+	}
+	if groups := GroupGoroutines(gs); !reflect.DeepEqual(groups, want) {
+		t.Errorf("GroupGoroutines = %+v, want %+v", groups, want)
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-stop
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	locked := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		close(locked)
+		<-stop
+	}()
+	<-locked
+
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)))
+	DumpGoroutineGroups(logger, WARN, "goroutines")
+
+	var count, lockedCount int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("unmarshal %s: %v", line, err)
+		}
+		if m["caller"] != "olog/goroutine_test.go:94" || m["content"] != "goroutines" || m["level"] != "warn" {
+			t.Errorf("record = %v", m)
+		}
+		frames, _ := m["frames"].([]any)
+		if len(frames) == 0 {
+			t.Fatalf("frames = %v, want an array", m["frames"])
+		}
+		if f, _ := frames[0].(map[string]any); f["function"] == "github.com/welllog/olog.TestDumpGoroutines.func1" {
+			// the goroutines may be grouped by different states if they are not blocked yet.
+			if n, ok := m["count"].(float64); ok {
+				count += int(n)
+			} else {
+				count++
+			}
+		} else if m["locked"] == true && strings.HasPrefix(f["function"].(string), "github.com/welllog/olog.TestDumpGoroutines.func") {
+			lockedCount++
+		}
+	}
+	if lockedCount != 1 {
+		t.Errorf("dumped %d locked goroutines of the test, want 1: %s", lockedCount, buf.String())
+	}
+	if count != 3 {
+		t.Errorf("dumped %d goroutines of the test, want 3: %s", count, buf.String())
+	}
+
+	buf.Reset()
+	DumpGoroutines(NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerLevel(ERROR)), WARN, "goroutines")
+	if buf.Len() != 0 {
+		t.Errorf("output = %q, want nothing for the disabled level", buf.String())
+	}
+}
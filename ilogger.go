@@ -52,6 +52,8 @@ type Logger interface {
 
 	log(r Record)
 	buildFields(fields ...Field) []Field
+	named(name string) Logger
+	duplicatePolicy() DuplicatePolicy
 }
 
 // Field is a struct that represents a key-value pair of additional data to include in a log message.
@@ -74,22 +76,28 @@ const (
 )
 
 // EncodeFunc is a function that encodes a log message to a byte slice.
+// The Fields and MsgArgs of the record are reused after the call, they must not be retained.
 type EncodeFunc func(Record, *encoder.Buffer)
 
 type Record struct {
-	Level       Level    // Level is the severity level of the log message.
-	Caller      EnableOp // Caller is the enable of caller information in the log message.
-	Stack       EnableOp // Stack is the enable of stack trace information in the log message.
-	ShortFile   EnableOp // ShortFile is the enable of short file name in the log message.
-	StackSize   uint8    // StackSize is the maximum number of stack frames to include in the log message.
-	CallerSkip  int8     // CallerSkip is the number of stack frames to skip to find the caller information.
-	OsExit      bool     // OsExit is the enable of os.Exit(1) in the log message.
-	MsgOrFormat string   // MsgOrFormat is the string representation of the log message
-	MsgArgs     []any    // MsgArgs is the arguments of the log message
-	Fields      []Field  // Fields is a slice of key-value pairs of additional data to include in the log message.
-	LevelTag    string   // LevelTag is the string representation of the severity level
-	App         string   // App is the name of the application that created the log message.
-	TimeFmt     string   // TimeFmt is the format string of the log message.
+	Level       Level           // Level is the severity level of the log message.
+	Caller      EnableOp        // Caller is the enable of caller information in the log message.
+	Stack       EnableOp        // Stack is the enable of stack trace information in the log message.
+	ShortFile   EnableOp        // ShortFile is the enable of short file name in the log message.
+	RichError   EnableOp        // RichError is the enable of the error fields with their wrapped chain, verbose output and stack.
+	StackOpts   *StackOptions   // StackOpts configures the stack trace, the stack is written as a string if it is nil.
+	CallerOpts  *CallerOptions  // CallerOpts configures the caller, the ShortFile is used if it is nil.
+	Duplicates  DuplicatePolicy // Duplicates is the policy for the fields with duplicate keys and the keys of the encoding.
+	StackSize   uint8           // StackSize is the maximum number of stack frames to include in the log message.
+	CallerSkip  int8            // CallerSkip is the number of stack frames to skip to find the caller information.
+	OsExit      bool            // OsExit is the enable of os.Exit(1) in the log message.
+	MsgOrFormat string          // MsgOrFormat is the string representation of the log message
+	MsgArgs     []any           // MsgArgs is the arguments of the log message
+	Fields      []Field         // Fields is a slice of key-value pairs of additional data to include in the log message.
+	LevelTag    string          // LevelTag is the string representation of the severity level
+	App         string          // App is the name of the application that created the log message.
+	Name        string          // Name is the hierarchical name of the logger created by Named, such as "svc.db".
+	TimeFmt     string          // TimeFmt is the format string of the log message.
 	Time        time.Time
 }
 
@@ -0,0 +1,68 @@
+// Package testserver provides the fake HTTP server recording the requests for the tests of the writers.
+package testserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Request is a request recorded by a Server.
+type Request struct {
+	Path   string
+	Header http.Header
+	Body   []byte // Body is the body of the request, decompressed if it is gzipped
+}
+
+// Server is a fake HTTP server recording the requests, which are responded with the status codes in order,
+// and with the default status once they run out.
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []Request
+	statuses []int
+}
+
+// New starts a Server responding with the status and the body, the server is closed when the test finishes.
+func New(t *testing.T, status int, body string, statuses ...int) *Server {
+	s := &Server{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip reader: %v", err)
+				return
+			}
+			reader = zr
+		}
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Path: r.URL.Path, Header: r.Header.Clone(), Body: b})
+		code := status
+		if len(s.statuses) > 0 {
+			code = s.statuses[0]
+			s.statuses = s.statuses[1:]
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests recorded so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
@@ -0,0 +1,262 @@
+//go:build linux
+
+package olog
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// defJournalSocket is the path of the systemd-journald native protocol socket.
+const defJournalSocket = "/run/systemd/journal/socket"
+
+// JournalWriter is a Writer that sends log messages to systemd-journald using the native protocol.
+// It must be used together with its Encode method as the EncodeFunc of the logger, which encodes the
+// Record as journal fields:
+//
+//	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode))
+type JournalWriter struct {
+	addr *net.UnixAddr
+	conn *net.UnixConn
+}
+
+// JournalOption is a functional option type for configuring a JournalWriter.
+type JournalOption func(*JournalWriter)
+
+// WithJournalSocket sets the path of the journald socket, "/run/systemd/journal/socket" is used by default.
+func WithJournalSocket(path string) JournalOption {
+	return func(j *JournalWriter) {
+		j.addr = &net.UnixAddr{Name: path, Net: "unixgram"}
+	}
+}
+
+// NewJournalWriter creates a new JournalWriter.
+func NewJournalWriter(opts ...JournalOption) (*JournalWriter, error) {
+	j := &JournalWriter{
+		addr: &net.UnixAddr{Name: defJournalSocket, Net: "unixgram"},
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	j.conn = conn
+	return j, nil
+}
+
+// IsJournalAvailable returns whether the systemd-journald socket is available.
+func IsJournalAvailable() bool {
+	_, err := os.Stat(defJournalSocket)
+	return err == nil
+}
+
+// Write sends the journal entry encoded by Encode to journald. The entry is passed by a file descriptor
+// when it is too large for a datagram.
+func (j *JournalWriter) Write(level Level, p []byte) (n int, err error) {
+	_, _, err = j.conn.WriteMsgUnix(p, nil, j.addr)
+	if err == nil {
+		return len(p), nil
+	}
+
+	if !isMsgSizeErr(err) {
+		return 0, err
+	}
+
+	if err = j.writeFile(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to journald.
+func (j *JournalWriter) Close() error {
+	return j.conn.Close()
+}
+
+// journalTempDirs are the directories tried in order to create the temporary files of the large entries,
+// the empty string is the default directory for temporary files.
+var journalTempDirs = []string{"/dev/shm", ""}
+
+// writeFile writes the entry to a temporary file and sends its file descriptor to journald.
+// memfd_create is not exposed by the syscall package on all platforms, so an unlinked file
+// in the /dev/shm tmpfs is used instead, or in os.TempDir() if /dev/shm is unavailable, such as
+// in containers.
+func (j *JournalWriter) writeFile(p []byte) error {
+	var (
+		f   *os.File
+		err error
+	)
+	for _, dir := range journalTempDirs {
+		if f, err = os.CreateTemp(dir, "olog-journal-*"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = os.Remove(f.Name()); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(p); err != nil {
+		return err
+	}
+
+	_, _, err = j.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), j.addr)
+	return err
+}
+
+// isMsgSizeErr returns whether the error is caused by a too large datagram.
+func isMsgSizeErr(err error) bool {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno == syscall.EMSGSIZE || errno == syscall.ENOBUFS
+	}
+	return false
+}
+
+// Encode encodes the Record as journal fields to the buffer, it maps the level to PRIORITY, the caller to
+// CODE_FILE, CODE_LINE and CODE_FUNC, the message to MESSAGE, and each Field to an uppercase journal field.
+func (j *JournalWriter) Encode(r Record, buf *encoder.Buffer) {
+	scratch := getBuf()
+	defer putBuf(scratch)
+
+	scratch.WriteInt64(int64(syslogSeverity(r.Level)))
+	writeJournalField(buf, "PRIORITY", scratch.Bytes())
+
+	scratch.Reset()
+	_, _ = scratch.WriteString(r.LevelTag)
+	writeJournalField(buf, "LEVEL", scratch.Bytes())
+
+	scratch.Reset()
+	_, _ = encoder.EPrintf(encoder.PlainEncoder{Buffer: scratch}, r.MsgOrFormat, r.MsgArgs...)
+	writeJournalField(buf, "MESSAGE", scratch.Bytes())
+
+	if r.App != "" {
+		scratch.Reset()
+		_, _ = scratch.WriteString(r.App)
+		writeJournalField(buf, "SYSLOG_IDENTIFIER", scratch.Bytes())
+	}
+
+	if r.Name != "" {
+		scratch.Reset()
+		_, _ = scratch.WriteString(r.Name)
+		writeJournalField(buf, "LOGGER", scratch.Bytes())
+	}
+
+	frame, frames, more := r.FirstFrame()
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		scratch.Reset()
+		_, _ = scratch.WriteString(file)
+		writeJournalField(buf, "CODE_FILE", scratch.Bytes())
+
+		scratch.Reset()
+		scratch.WriteInt64(int64(frame.Line))
+		writeJournalField(buf, "CODE_LINE", scratch.Bytes())
+
+		scratch.Reset()
+		_, _ = scratch.WriteString(frame.Function)
+		writeJournalField(buf, "CODE_FUNC", scratch.Bytes())
+	}
+
+	if r.Stack.IsOpen() && frame.PC != 0 {
+		scratch.Reset()
+		WriteStackText(scratch, r.StackOpts, frame, frames, more, false, false)
+		writeJournalField(buf, "STACK", scratch.Bytes())
+	}
+
+	fields := flattenGroups(r.Fields)
+	defer putFields(fields)
+	dedup := newFieldDedup(r.Duplicates, *fields, filterField)
+	for i, field := range *fields {
+		key, ok := dedup.key(i)
+		if !ok {
+			continue
+		}
+		name := journalFieldName(key)
+		if name == "" {
+			continue
+		}
+		scratch.Reset()
+		encoder.PlainEncoder{Buffer: scratch}.WriteValue(field.Value)
+		writeJournalField(buf, name, scratch.Bytes())
+	}
+}
+
+// writeJournalField writes a journal field to the buffer, values containing newlines are written in
+// the binary safe form, which is the name, a newline, the little-endian 64-bit length and the value.
+func writeJournalField(buf *encoder.Buffer, name string, value []byte) {
+	_, _ = buf.WriteString(name)
+
+	binarySafe := false
+	for _, c := range value {
+		if c == '\n' {
+			binarySafe = true
+			break
+		}
+	}
+
+	if binarySafe {
+		_ = buf.WriteByte('\n')
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		_, _ = buf.Write(size[:])
+	} else {
+		_ = buf.WriteByte('=')
+	}
+	_, _ = buf.Write(value)
+	_ = buf.WriteByte('\n')
+}
+
+// journalReserved is the journal fields written by Encode, the fields with these names are prefixed by "F_".
+var journalReserved = map[string]struct{}{
+	"PRIORITY":          {},
+	"LEVEL":             {},
+	"MESSAGE":           {},
+	"SYSLOG_IDENTIFIER": {},
+	"LOGGER":            {},
+	"CODE_FILE":         {},
+	"CODE_LINE":         {},
+	"CODE_FUNC":         {},
+	"STACK":             {},
+}
+
+// journalFieldName converts the key to a journal field name, which consists of uppercase letters, digits
+// and underscores, starts with a letter and is no longer than 64 bytes. An empty string is returned if
+// the key has no letters or digits, and the names of the fields written by Encode are prefixed by "F_".
+func journalFieldName(key string) string {
+	b := make([]byte, 0, len(key)+2)
+	for i := 0; i < len(key) && len(b) < 64; i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			c = '_'
+		}
+
+		if len(b) == 0 && (c < 'A' || c > 'Z') {
+			if c == '_' {
+				continue
+			}
+			b = append(b, 'F', '_')
+		}
+		b = append(b, c)
+	}
+	if _, ok := journalReserved[string(b)]; ok {
+		return "F_" + string(b)
+	}
+	return string(b)
+}
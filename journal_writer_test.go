@@ -0,0 +1,182 @@
+//go:build linux
+
+package olog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// parseJournalEntry parses the entry of journald native protocol into fields.
+func parseJournalEntry(t *testing.T, b []byte) map[string]string {
+	m := make(map[string]string)
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if i < 0 {
+			t.Fatalf("invalid entry %q", b)
+		}
+		name := string(b[:i])
+		if b[i] == '=' {
+			j := bytes.IndexByte(b, '\n')
+			m[name] = string(b[i+1 : j])
+			b = b[j+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(b[i+1 : i+9])
+		m[name] = string(b[i+9 : i+9+int(size)])
+		b = b[i+9+int(size)+1:]
+	}
+	return m
+}
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	return conn, path
+}
+
+func TestJournalWriter(t *testing.T) {
+	server, path := listenJournal(t)
+	defer server.Close()
+
+	w, err := NewJournalWriter(WithJournalSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncodeFunc(w.Encode), WithLoggerAppName("app"))
+	logger.Warnw("hello\nworld", Field{Key: "user-id", Value: 3}, Field{Key: "_secret", Value: "x"}, Field{Key: "9lives", Value: true},
+		Field{Key: "message", Value: "user"}, Field{Key: "code_file", Value: "user.go"})
+
+	b := make([]byte, 4096)
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := parseJournalEntry(t, b[:n])
+	want := map[string]string{
+		"PRIORITY":          "4",
+		"LEVEL":             "warn",
+		"MESSAGE":           "hello\nworld",
+		"SYSLOG_IDENTIFIER": "app",
+		"CODE_FILE":         "olog/journal_writer_test.go",
+		"CODE_LINE":         "61",
+		"CODE_FUNC":         "github.com/welllog/olog.TestJournalWriter",
+		"USER_ID":           "3",
+		"SECRET":            "x",
+		"F_9LIVES":          "true",
+		"F_MESSAGE":         "user",
+		"F_CODE_FILE":       "user.go",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("field %s = %q, want %q", k, m[k], v)
+		}
+	}
+	if len(m) != len(want) {
+		t.Errorf("fields = %v, want %v", m, want)
+	}
+}
+
+func TestJournalWriterLargeEntry(t *testing.T) {
+	defer func(dirs []string) { journalTempDirs = dirs }(journalTempDirs)
+
+	for _, dir := range []string{"/dev/shm", filepath.Join(t.TempDir(), "missing")} {
+		// the default directory for temporary files is the fallback.
+		journalTempDirs = []string{dir, ""}
+		testJournalWriterLargeEntry(t)
+	}
+}
+
+func testJournalWriterLargeEntry(t *testing.T) {
+	server, path := listenJournal(t)
+	defer server.Close()
+
+	w, err := NewJournalWriter(WithJournalSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	msg := strings.Repeat("a", 512*1024)
+	entry := []byte("MESSAGE=" + msg + "\n")
+	_, err = w.Write(INFO, entry)
+	if err != nil {
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 64)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := server.ReadMsgUnix(b, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("datagram size = %d, want 0", n)
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("parse control message: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("parse unix rights: %v", err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	_, _ = f.Seek(0, io.SeekStart)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, entry) {
+		t.Errorf("entry size = %d, want %d", len(data), len(entry))
+	}
+}
+
+func TestJournalStackOptions(t *testing.T) {
+	testStackOptionsEncode(t, "journal", (&JournalWriter{}).Encode)
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := []struct {
+		key  string
+		name string
+	}{
+		{key: "name", name: "NAME"},
+		{key: "request.id", name: "REQUEST_ID"},
+		{key: "__x", name: "X"},
+		{key: "1a", name: "F_1A"},
+		{key: "___", name: ""},
+		{key: "message", name: "F_MESSAGE"},
+		{key: "code.file", name: "F_CODE_FILE"},
+		{key: strings.Repeat("a", 80), name: strings.Repeat("A", 64)},
+	}
+	for _, tt := range tests {
+		if name := journalFieldName(tt.key); name != tt.name {
+			t.Errorf("journalFieldName(%q) = %q, want %q", tt.key, name, tt.name)
+		}
+	}
+}
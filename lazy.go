@@ -0,0 +1,70 @@
+package olog
+
+// LazyValue is the value of a field evaluated only when the record is encoded, so that the costly values
+// cost nothing if the level is disabled.
+type LazyValue func() any
+
+// Lazy returns a field whose value is evaluated by f only when the record is encoded. It can be attached to
+// the base loggers by WithFields, f is called for each record encoded.
+func Lazy(key string, f func() any) Field {
+	return Field{Key: key, Value: LazyValue(f)}
+}
+
+// LazyMessage is a message evaluated only when the record is encoded, it can be used as the args of the
+// logging methods, such as logger.Debugf("state: %s", LazyMessage(dump)).
+type LazyMessage func() string
+
+// String returns the message.
+func (m LazyMessage) String() string {
+	return m()
+}
+
+// hasLazyFields reports whether any of the fields has a value evaluated when the record is encoded, which
+// is a LazyValue or a slog.LogValuer, or any of the fields is a Group if the groups are flattened.
+func hasLazyFields(fields []Field, flatten bool) bool {
+	for _, field := range fields {
+		switch value := field.Value.(type) {
+		case LazyValue:
+			return true
+		case Group:
+			if flatten || hasLazyFields(value, false) {
+				return true
+			}
+		default:
+			if isLogValuer(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveFields appends the fields to dst with the lazy values evaluated, the keys are prefixed by the prefix
+// and ".". The groups are flattened to the fields with the keys prefixed by the key of the group if flatten.
+func resolveFields(dst, fields []Field, prefix string, flatten bool) []Field {
+	for _, field := range fields {
+		key := field.Key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		value := field.Value
+		if f, ok := value.(LazyValue); ok {
+			value = f()
+		}
+		if isLogValuer(value) {
+			value = resolveLogValuer(value)
+		}
+		if group, ok := value.(Group); ok {
+			if flatten {
+				dst = resolveFields(dst, group, key, true)
+				continue
+			}
+			if hasLazyFields(group, false) {
+				value = Group(resolveFields(nil, group, "", false))
+			}
+		}
+		dst = append(dst, Field{Key: key, Value: value})
+	}
+	return dst
+}
@@ -0,0 +1,11 @@
+//go:build !go1.21
+
+package olog
+
+func isLogValuer(value any) bool {
+	return false
+}
+
+func resolveLogValuer(value any) any {
+	return value
+}
@@ -0,0 +1,44 @@
+//go:build go1.21
+
+package olog
+
+import "log/slog"
+
+// isLogValuer reports whether the value is a slog.LogValuer.
+func isLogValuer(value any) bool {
+	_, ok := value.(slog.LogValuer)
+	return ok
+}
+
+// resolveLogValuer returns the resolved value of the slog.LogValuer, the groups are returned as Group.
+func resolveLogValuer(value any) any {
+	return slogValue(slog.AnyValue(value).Resolve())
+}
+
+// slogValue returns the value of the slog value, the groups are returned as Group and the groups with empty
+// keys are inlined.
+func slogValue(value slog.Value) any {
+	if value.Kind() != slog.KindGroup {
+		return value.Any()
+	}
+	return slogGroup(nil, value.Group())
+}
+
+// slogGroup appends the attrs to the group as fields, the groups with empty keys are inlined and the empty
+// groups are dropped.
+func slogGroup(group Group, attrs []slog.Attr) Group {
+	for _, attr := range attrs {
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			if attr.Key == "" {
+				group = slogGroup(group, value.Group())
+				continue
+			}
+			if len(value.Group()) == 0 {
+				continue
+			}
+		}
+		group = append(group, Field{Key: attr.Key, Value: slogValue(value)})
+	}
+	return group
+}
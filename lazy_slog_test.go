@@ -0,0 +1,47 @@
+//go:build go1.21
+
+package olog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	name  string
+	token string
+}
+
+func (u testUser) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", u.name), slog.Any("token", redacted(u.token)))
+}
+
+type redacted string
+
+func (redacted) LogValue() slog.Value {
+	return slog.StringValue("***")
+}
+
+func TestLogValuer(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false))
+	logger.Infow("login", Field{Key: "user", Value: testUser{name: "bob", token: "secret"}}, Field{Key: "password", Value: redacted("secret")})
+	if want := `"content":"login","user":{"name":"bob","token":"***"},"password":"***"}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	slog.New(NewSlogHandler(logger)).With("user", testUser{name: "alice"}).Info("login")
+	if want := `"content":"login","user":{"name":"alice","token":"***"}}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger = NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncode(PLAIN))
+	logger.Infow("login", Field{Key: "user", Value: testUser{name: "bob", token: "secret"}})
+	if want := "\tlogin\tuser.name=bob\tuser.token=***\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %q, want suffix %q", buf.String(), want)
+	}
+}
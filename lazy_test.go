@@ -0,0 +1,54 @@
+package olog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	var calls int
+	digest := Lazy("digest", func() any {
+		calls++
+		return 42
+	})
+	message := LazyMessage(func() string {
+		calls++
+		return "dump"
+	})
+
+	var buf bytes.Buffer
+	logger := WithFields(NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerLevel(INFO)), digest)
+	logger.Debugf("state: %s", message)
+	logger.Debugw("state", Lazy("size", func() any {
+		calls++
+		return 1
+	}))
+	if calls != 0 || buf.Len() != 0 {
+		t.Fatalf("evaluated %d times for the disabled level: %q", calls, buf.String())
+	}
+
+	logger.Infof("state: %s", message)
+	if want := `"content":"state: dump","digest":42}` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+	if calls != 2 {
+		t.Errorf("evaluated %d times, want 2", calls)
+	}
+}
+
+func TestLazyConsole(t *testing.T) {
+	var calls int
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncode(CONSOLE), WithLoggerColor(false))
+	logger.Infow("state", Lazy("size", func() any {
+		calls++
+		return 1
+	}))
+	if !strings.Contains(buf.String(), "size=1") {
+		t.Errorf("output = %q, want size=1", buf.String())
+	}
+	if calls != 1 {
+		t.Errorf("evaluated %d times, want 1", calls)
+	}
+}
@@ -1,6 +1,15 @@
 package olog
 
-import "strings"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
 
 // Level is an unsigned 8-bit integer that represents the log level.
 type Level uint8
@@ -16,6 +25,10 @@ const (
 	FATAL
 )
 
+// severityStep is the distance between the severities of two adjacent built-in levels, the gap leaves
+// room for custom levels.
+const severityStep = 10
+
 // Define string representations of each log level.
 const (
 	tagTrace  = "trace"
@@ -27,36 +40,341 @@ const (
 	tagFatal  = "fatal"
 )
 
-// Define a mapping from Level values to their corresponding string representations.
-var levelToStr = map[Level]string{
-	TRACE:  tagTrace,
-	DEBUG:  tagDebug,
-	INFO:   tagInfo,
-	NOTICE: tagNotice,
-	WARN:   tagWarn,
-	ERROR:  tagError,
-	FATAL:  tagFatal,
-}
-
-// Define a mapping from string representations to their corresponding Level values.
-var strToLevel = map[string]Level{
-	tagTrace:  TRACE,
-	tagDebug:  DEBUG,
-	tagInfo:   INFO,
-	tagNotice: NOTICE,
-	tagWarn:   WARN,
-	"warning": WARN,
-	tagError:  ERROR,
-	"err":     ERROR,
-	tagFatal:  FATAL,
+// LevelDesc describes a level held by the level registry.
+type LevelDesc struct {
+	Level    Level  // Level is the value of the level, custom levels take the values above FATAL.
+	Severity int    // Severity orders the level among the others for filtering, see Level.Severity.
+	Tag      string // Tag is the string representation of the level, used for output and parsing.
+	Color    string // Color is the ANSI escape sequence to colorize the tag on plain encoding.
+	Stderr   bool   // Stderr is whether the console writer outputs the level to the standard error.
+}
+
+// levelRegistry holds the registered levels, it is never modified after being published.
+type levelRegistry struct {
+	descs    [256]*LevelDesc
+	names    map[string]Level
+	severity [256]int // severity is the severity of every level value, computed on registration
+	ordered  []Level  // ordered is the registered levels sorted by severity
+}
+
+var (
+	// lr stores the current level registry.
+	lr atomic.Value
+	// lrMu serializes the registrations.
+	lrMu sync.Mutex
+)
+
+// init registers the built-in levels.
+func init() {
+	r := &levelRegistry{
+		names: map[string]Level{
+			"warning": WARN,
+			"err":     ERROR,
+		},
+	}
+	for _, desc := range []LevelDesc{
+		{Level: TRACE, Tag: tagTrace, Color: Cyan},
+		{Level: DEBUG, Tag: tagDebug, Color: Gray},
+		{Level: INFO, Tag: tagInfo, Color: Green},
+		{Level: NOTICE, Tag: tagNotice, Color: Blue},
+		{Level: WARN, Tag: tagWarn, Color: Yellow, Stderr: true},
+		{Level: ERROR, Tag: tagError, Color: Red, Stderr: true},
+		{Level: FATAL, Tag: tagFatal, Color: RedBold, Stderr: true},
+	} {
+		desc := desc
+		desc.Severity = int(desc.Level) * severityStep
+		r.descs[desc.Level] = &desc
+		r.names[desc.Tag] = desc.Level
+	}
+	r.order()
+	lr.Store(r)
+}
+
+// order computes the severities of the level values and sorts the registered levels by severity.
+// The levels not registered have the severity of the nearest lower registered level plus the distance.
+func (r *levelRegistry) order() {
+	r.ordered = r.ordered[:0]
+	for l := range r.descs {
+		if desc := r.descs[l]; desc != nil {
+			r.severity[l] = desc.Severity
+			r.ordered = append(r.ordered, Level(l))
+		} else {
+			r.severity[l] = r.severity[l-1] + 1
+		}
+	}
+	sort.SliceStable(r.ordered, func(i, j int) bool {
+		return r.severity[r.ordered[i]] < r.severity[r.ordered[j]]
+	})
+}
+
+// getLevelRegistry returns the current level registry.
+func getLevelRegistry() *levelRegistry {
+	return lr.Load().(*levelRegistry)
+}
+
+// RegisterLevel registers a custom level, so that it can be parsed by its tag, and is colorized and
+// routed by the console writer as described. The value of the level must be above FATAL, so that the
+// values of the built-in levels are kept, and the level is filtered by its severity, such as
+// WARN.Severity() - 2 for a level between NOTICE and WARN. Neither the value, the tag nor the severity can be
+// registered twice, and the severity must be set, since 0 is the severity of TRACE.
+func RegisterLevel(desc LevelDesc) error {
+	tag := strings.ToLower(strings.TrimSpace(desc.Tag))
+	if tag == "" {
+		return errors.New("olog: level tag is empty")
+	}
+	if strings.ContainsAny(tag, "+-") {
+		return fmt.Errorf("olog: level tag %q contains offset sign", desc.Tag)
+	}
+	if desc.Level <= FATAL {
+		return fmt.Errorf("olog: level %d is not above the built-in levels", desc.Level)
+	}
+
+	lrMu.Lock()
+	defer lrMu.Unlock()
+
+	old := getLevelRegistry()
+	if d := old.descs[desc.Level]; d != nil {
+		return fmt.Errorf("olog: level %d is already registered as %q", desc.Level, d.Tag)
+	}
+	if _, ok := old.names[tag]; ok {
+		return fmt.Errorf("olog: level tag %q is already registered", desc.Tag)
+	}
+	if desc.Severity == 0 {
+		return fmt.Errorf("olog: level %q has no severity", desc.Tag)
+	}
+	for _, l := range old.ordered {
+		if old.severity[l] == desc.Severity {
+			return fmt.Errorf("olog: level severity %d is already registered by %q", desc.Severity, old.descs[l].Tag)
+		}
+	}
+
+	r := &levelRegistry{
+		descs: old.descs,
+		names: make(map[string]Level, len(old.names)+1),
+	}
+	for k, v := range old.names {
+		r.names[k] = v
+	}
+
+	desc.Tag = EscapedString(desc.Tag)
+	r.descs[desc.Level] = &desc
+	r.names[tag] = desc.Level
+	r.order()
+
+	lr.Store(r)
+	return nil
+}
+
+// unregisterLevel removes the registered custom level, it is used by the tests to restore the registry.
+func unregisterLevel(level Level) {
+	lrMu.Lock()
+	defer lrMu.Unlock()
+
+	old := getLevelRegistry()
+	desc := old.descs[level]
+	if desc == nil {
+		return
+	}
+
+	r := &levelRegistry{
+		descs: old.descs,
+		names: make(map[string]Level, len(old.names)),
+	}
+	for k, v := range old.names {
+		if v != level {
+			r.names[k] = v
+		}
+	}
+	r.descs[level] = nil
+	r.order()
+
+	lr.Store(r)
+}
+
+// GetLevelDesc returns the description of the registered level.
+func GetLevelDesc(level Level) (LevelDesc, bool) {
+	desc := getLevelRegistry().descs[level]
+	if desc == nil {
+		return LevelDesc{}, false
+	}
+	return *desc, true
+}
+
+// Severity returns the severity of the level, the levels are filtered by comparing their severities.
+// The built-in levels have the severities 0, 10, ..., 60 from TRACE to FATAL, the custom levels have the
+// registered ones, and the levels not registered follow the nearest lower registered level.
+func (l Level) Severity() int {
+	return getLevelRegistry().severity[l]
+}
+
+// levelBySeverity returns the registered level with the highest severity not above the severity,
+// or TRACE if there is none.
+func levelBySeverity(severity int) Level {
+	r := getLevelRegistry()
+	level := TRACE
+	for _, l := range r.ordered {
+		if r.severity[l] > severity {
+			break
+		}
+		level = l
+	}
+	return level
 }
 
 // String returns the string representation of the Level value.
+// Levels not registered are represented as an offset from the nearest lower one, such as "fatal+2".
 func (l Level) String() string {
-	return levelToStr[l]
+	r := getLevelRegistry()
+	if desc := r.descs[l]; desc != nil {
+		return desc.Tag
+	}
+
+	base := l
+	for r.descs[base] == nil {
+		base--
+	}
+	return r.descs[base].Tag + "+" + strconv.Itoa(int(l-base))
+}
+
+// Set implements flag.Value, it parses the string with ParseLevel.
+func (l *Level) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, it parses the text with ParseLevel.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, the level is encoded as a JSON string.
+func (l Level) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, l.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the level is decoded from a JSON string parsed with
+// ParseLevel, or from a JSON number which is the numeric value of the level. A JSON null leaves the level
+// unchanged.
+func (l *Level) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		return l.UnmarshalText([]byte(s))
+	}
+	return l.UnmarshalText(data)
+}
+
+// ParseLevel returns the Level value corresponding to the given string.
+// The string is case-insensitive and may carry an offset like "fatal+2", which is the form String
+// returns for levels not registered, or be the numeric value of the level like "2" for INFO.
+// An error is returned for unknown strings.
+func ParseLevel(s string) (Level, error) {
+	r := getLevelRegistry()
+	name := strings.ToLower(strings.TrimSpace(s))
+	if level, ok := r.names[name]; ok {
+		return level, nil
+	}
+
+	if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return Level(n), nil
+	}
+
+	if i := strings.IndexByte(name, '+'); i > 0 {
+		level, ok := r.names[name[:i]]
+		if ok {
+			offset, err := strconv.Atoi(name[i+1:])
+			if err == nil && offset > 0 && int(level)+offset <= 255 {
+				// only the form returned by String is accepted, the offset must not reach another level.
+				if l := Level(int(level) + offset); strings.EqualFold(l.String(), name) {
+					return l, nil
+				}
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("olog: unknown level %q", s)
 }
 
 // GetLevelByString returns the Level value corresponding to the given string.
+// It returns TRACE for unknown strings, use ParseLevel to detect them.
 func GetLevelByString(s string) Level {
-	return strToLevel[strings.ToLower(s)]
+	return getLevelRegistry().names[strings.ToLower(s)]
+}
+
+// LevelFlag defines a Level flag with specified name, default value, and usage string.
+// The return value is the address of a Level variable that stores the value of the flag.
+func LevelFlag(name string, value Level, usage string) *Level {
+	return LevelFlagSet(flag.CommandLine, name, value, usage)
+}
+
+// LevelFlagSet defines a Level flag with specified name, default value, and usage string on the flag set.
+// The return value is the address of a Level variable that stores the value of the flag.
+func LevelFlagSet(fs *flag.FlagSet, name string, value Level, usage string) *Level {
+	p := new(Level)
+	*p = value
+	fs.Var(p, name, usage)
+	return p
+}
+
+// isStderrLevel returns whether the level should be output to the standard error by the console writer.
+func isStderrLevel(level Level) bool {
+	if desc := getLevelRegistry().descs[level]; desc != nil {
+		return desc.Stderr
+	}
+	return level.Severity() >= WARN.Severity()
+}
+
+// LevelVar is a Level variable, to allow the level of many loggers to be changed dynamically.
+// It is safe for concurrent use, the zero LevelVar corresponds to TRACE.
+type LevelVar struct {
+	val uint32
+}
+
+// NewLevelVar returns a new LevelVar set to the given level.
+func NewLevelVar(level Level) *LevelVar {
+	return &LevelVar{val: uint32(level)}
+}
+
+// Level returns the current level of the variable.
+func (v *LevelVar) Level() Level {
+	return Level(atomic.LoadUint32(&v.val))
+}
+
+// Set sets the level of the variable.
+func (v *LevelVar) Set(level Level) {
+	atomic.StoreUint32(&v.val, uint32(level))
+}
+
+// String returns the string representation of the LevelVar.
+func (v *LevelVar) String() string {
+	return "LevelVar(" + v.Level().String() + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v *LevelVar) MarshalText() ([]byte, error) {
+	return v.Level().MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, it parses the text with ParseLevel.
+func (v *LevelVar) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	v.Set(level)
+	return nil
 }
@@ -0,0 +1,252 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s     string
+		level Level
+		err   bool
+	}{
+		{s: "trace", level: TRACE},
+		{s: "DEBUG", level: DEBUG},
+		{s: " info ", level: INFO},
+		{s: "notice", level: NOTICE},
+		{s: "warning", level: WARN},
+		{s: "err", level: ERROR},
+		{s: "fatal", level: FATAL},
+		{s: "fatal+2", level: FATAL + 2},
+		{s: "2", level: INFO},
+		{s: "255", level: 255},
+		{s: "256", err: true},
+		{s: "info+2", err: true},
+		{s: "error-10", err: true},
+		{s: "", err: true},
+		{s: "verbose", err: true},
+		{s: "trace-1", err: true},
+		{s: "info+x", err: true},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.s)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseLevel(%q) expected error, got %s", tt.s, level)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLevel(%q) unexpected error: %v", tt.s, err)
+			continue
+		}
+		if level != tt.level {
+			t.Errorf("ParseLevel(%q) = %s, want %s", tt.s, level, tt.level)
+		}
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	for level := TRACE; level < FATAL+3; level++ {
+		s := level.String()
+		if s == "" {
+			t.Fatalf("Level(%d).String() is empty", level)
+		}
+		got, err := ParseLevel(s)
+		if err != nil {
+			t.Fatalf("ParseLevel(%q) unexpected error: %v", s, err)
+		}
+		if got != level {
+			t.Fatalf("ParseLevel(%q) = %d, want %d", s, got, level)
+		}
+	}
+
+	if s := (FATAL + 1).String(); s != "fatal+1" {
+		t.Errorf("(FATAL + 1).String() = %s, want fatal+1", s)
+	}
+}
+
+func TestLevelMarshal(t *testing.T) {
+	type config struct {
+		Level Level `json:"level"`
+	}
+
+	b, err := json.Marshal(config{Level: WARN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"level":"warn"}` {
+		t.Errorf("json.Marshal = %s, want %s", b, `{"level":"warn"}`)
+	}
+
+	var c config
+	if err := json.Unmarshal([]byte(`{"level":"Error"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != ERROR {
+		t.Errorf("json.Unmarshal level = %s, want %s", c.Level, ERROR)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":2}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != INFO {
+		t.Errorf("json.Unmarshal level = %s, want %s", c.Level, INFO)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":null}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != INFO {
+		t.Errorf("json.Unmarshal null level = %s, want %s", c.Level, INFO)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"unknown"}`), &c); err == nil {
+		t.Error("json.Unmarshal expected error for unknown level")
+	}
+}
+
+func TestLevelFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := INFO
+	fs.Var(&level, "level", "log level")
+
+	if err := fs.Parse([]string{"-level", "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if level != DEBUG {
+		t.Errorf("level = %s, want %s", level, DEBUG)
+	}
+
+	if err := fs.Parse([]string{"-level", "nope"}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	p := LevelFlagSet(fs, "level", NOTICE, "log level")
+	if *p != NOTICE {
+		t.Errorf("LevelFlagSet default = %s, want %s", *p, NOTICE)
+	}
+	if err := fs.Parse([]string{"-level", "error"}); err != nil {
+		t.Fatal(err)
+	}
+	if *p != ERROR {
+		t.Errorf("level = %s, want %s", *p, ERROR)
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	slow := FATAL + 1
+	if err := RegisterLevel(LevelDesc{Level: slow, Severity: WARN.Severity() - 2, Tag: "Slow", Color: Purple, Stderr: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unregisterLevel(slow) })
+
+	tests := []LevelDesc{
+		{Level: slow, Tag: "slow2", Severity: WARN.Severity() - 1},
+		{Level: slow + 1, Tag: "slow"},
+		{Level: slow + 1, Tag: "info"},
+		{Level: slow + 1, Tag: ""},
+		{Level: slow + 1, Tag: "a+b"},
+		{Level: INFO, Tag: "info2"},
+		{Level: slow + 1, Tag: "slow2"},
+		{Level: slow + 1, Tag: "slow2", Severity: WARN.Severity() - 2},
+		{Level: slow + 1, Tag: "slow2", Severity: WARN.Severity()},
+	}
+	for _, tt := range tests {
+		if err := RegisterLevel(tt); err == nil {
+			t.Errorf("RegisterLevel(%+v) expected error", tt)
+		}
+	}
+
+	if s := slow.String(); s != "Slow" {
+		t.Errorf("slow.String() = %s, want Slow", s)
+	}
+	if s := (slow + 1).String(); s != "Slow+1" {
+		t.Errorf("(slow + 1).String() = %s, want Slow+1", s)
+	}
+	if level, err := ParseLevel("SLOW"); err != nil || level != slow {
+		t.Errorf("ParseLevel(SLOW) = %s, %v, want %s", level, err, slow)
+	}
+	if level := GetLevelByString("slow"); level != slow {
+		t.Errorf("GetLevelByString(slow) = %s, want %s", level, slow)
+	}
+	if desc, ok := GetLevelDesc(slow); !ok || desc.Color != Purple {
+		t.Errorf("GetLevelDesc(slow) = %+v, %t", desc, ok)
+	}
+	if s := (slow + 1).Severity(); s != WARN.Severity()-1 {
+		t.Errorf("(slow + 1).Severity() = %d, want %d", s, WARN.Severity()-1)
+	}
+
+	var sw, ew bytes.Buffer
+	logger := NewLogger(
+		WithLoggerWriter(&consoleWriter{sw: &sw, ew: &ew}),
+		WithLoggerLevel(slow),
+		WithLoggerEncode(PLAIN),
+		WithLoggerColor(true),
+		WithLoggerCaller(false),
+		WithLoggerTimeFormat(""),
+	)
+	logger.Log(Record{Level: slow, MsgOrFormat: "slow query"})
+	logger.Log(Record{Level: NOTICE, MsgOrFormat: "skip"})
+
+	want := "\t" + Purple + "Slow" + Reset + "\tslow query\n"
+	if !colorSupported() {
+		want = "\tSlow\tslow query\n"
+	}
+	if ew.String() != want {
+		t.Errorf("stderr = %q, want %q", ew.String(), want)
+	}
+	if sw.Len() != 0 {
+		t.Errorf("stdout = %q, want empty", sw.String())
+	}
+}
+
+func TestLevelVar(t *testing.T) {
+	v := NewLevelVar(WARN)
+
+	var buf bytes.Buffer
+	l1 := NewLogger(WithLoggerLevelVar(v), WithLoggerWriter(NewWriter(&buf)))
+	l2 := WithFields(NewLogger(WithLoggerLevelVar(v), WithLoggerWriter(NewWriter(&buf))), Field{Key: "k", Value: "v"})
+
+	for _, l := range []Logger{l1, l2} {
+		if l.IsEnabled(INFO) {
+			t.Errorf("INFO is enabled with level %s", v.Level())
+		}
+		if !l.IsEnabled(WARN) {
+			t.Errorf("WARN is disabled with level %s", v.Level())
+		}
+	}
+
+	v.Set(DEBUG)
+	for _, l := range []Logger{l1, l2} {
+		if !l.IsEnabled(DEBUG) {
+			t.Errorf("DEBUG is disabled with level %s", v.Level())
+		}
+		if l.IsEnabled(TRACE) {
+			t.Errorf("TRACE is enabled with level %s", v.Level())
+		}
+	}
+
+	l3 := NewLogger(WithLoggerLevelVar(v), WithLoggerLevel(ERROR))
+	if l3.IsEnabled(WARN) {
+		t.Error("WithLoggerLevel should override the level variable")
+	}
+
+	if err := v.UnmarshalText([]byte("notice")); err != nil {
+		t.Fatal(err)
+	}
+	if v.Level() != NOTICE {
+		t.Errorf("level = %s, want %s", v.Level(), NOTICE)
+	}
+	if s := v.String(); s != "LevelVar(notice)" {
+		t.Errorf("String() = %s, want LevelVar(notice)", s)
+	}
+	if err := v.UnmarshalText([]byte("unknown")); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
@@ -51,6 +51,15 @@ func SetAppName(name string) {
 func SetLevel(level Level) {
 	l := getDefLogger().clone()
 	l.level = level
+	l.levelVar = nil
+	setDefLogger(l)
+}
+
+// SetLevelVar sets the level variable for the default logger, the logging level can then be changed
+// through the variable.
+func SetLevelVar(v *LevelVar) {
+	l := getDefLogger().clone()
+	l.levelVar = v
 	setDefLogger(l)
 }
 
@@ -65,7 +74,8 @@ func SetCaller(enable bool) {
 	setDefLogger(l)
 }
 
-// SetColor sets whether or not to use colorized output levelTag on plain encoding for the default logger.
+// SetColor sets whether or not to use colorized output on plain and console encoding for the default logger,
+// overriding the detection by default, which only uses colors when the output is an interactive terminal.
 func SetColor(enable bool) {
 	l := getDefLogger().clone()
 	if enable {
@@ -76,6 +86,13 @@ func SetColor(enable bool) {
 	setDefLogger(l)
 }
 
+// SetTheme sets the theme to color the output on plain and console encoding for the default logger.
+func SetTheme(t *Theme) {
+	l := getDefLogger().clone()
+	l.theme = t
+	setDefLogger(l)
+}
+
 // SetShortFile sets whether or not to log the short file name for the default logger.
 func SetShortFile(enable bool) {
 	l := getDefLogger().clone()
@@ -87,6 +104,48 @@ func SetShortFile(enable bool) {
 	setDefLogger(l)
 }
 
+// SetRichError sets whether or not to write the error fields with their wrapped chain, verbose output and
+// stack for the default logger.
+func SetRichError(enable bool) {
+	l := getDefLogger().clone()
+	if enable {
+		l.richError = Enable
+	} else {
+		l.richError = Disable
+	}
+	setDefLogger(l)
+}
+
+// SetCallerOptions sets the options of the caller for the default logger.
+func SetCallerOptions(o *CallerOptions) {
+	l := getDefLogger().clone()
+	l.callerOpts = o
+	setDefLogger(l)
+}
+
+// SetStackOptions sets the options of the stack trace for the default logger.
+func SetStackOptions(o *StackOptions) {
+	l := getDefLogger().clone()
+	l.stackOpts = o
+	setDefLogger(l)
+}
+
+// SetDuplicatePolicy sets the policy for the fields with duplicate keys and the keys of the encoding for the
+// default logger.
+func SetDuplicatePolicy(p DuplicatePolicy) {
+	l := getDefLogger().clone()
+	l.duplicates = p
+	setDefLogger(l)
+}
+
+// SetNameOptions sets the options applied to the loggers created by Named with the name from the default
+// logger.
+func SetNameOptions(name string, opts ...LoggerOption) {
+	l := getDefLogger().clone()
+	WithLoggerNameOptions(name, opts...)(l)
+	setDefLogger(l)
+}
+
 // SetTimeFormat sets the time format string for the default logger.
 func SetTimeFormat(format string) {
 	l := getDefLogger().clone()
@@ -98,7 +157,7 @@ func SetTimeFormat(format string) {
 func SetEncode(e EncodeType) {
 	l := getDefLogger().clone()
 	switch e {
-	case PLAIN, JSON:
+	case PLAIN, JSON, CONSOLE:
 		l.encType = e
 	default:
 		l.encType = JSON
@@ -34,7 +34,66 @@ func putBuf(buf *encoder.Buffer) {
 	bufPool.Put(buf)
 }
 
+// maxPooledSlice is the maximum capacity of the field and arg slices returned to the pools.
+const maxPooledSlice = 64
+
+// fieldsPool and argsPool are the pools of the slices the variadic fields and args of the logging methods
+// are copied to, so that the variadic arguments do not escape and stay on the stack of the callers.
+var (
+	fieldsPool = sync.Pool{
+		New: func() interface{} {
+			fs := make([]Field, 0, 8)
+			return &fs
+		},
+	}
+	argsPool = sync.Pool{
+		New: func() interface{} {
+			as := make([]any, 0, 8)
+			return &as
+		},
+	}
+)
+
+// getFields returns a pooled slice of the fields followed by the more fields.
+func getFields(fields, more []Field) *[]Field {
+	fs := fieldsPool.Get().(*[]Field)
+	*fs = append(append(*fs, fields...), more...)
+	return fs
+}
+
+// putFields returns the slice to the pool, the fields must not be used after that.
+func putFields(fs *[]Field) {
+	if cap(*fs) > maxPooledSlice {
+		return
+	}
+	for i := range *fs {
+		(*fs)[i] = Field{}
+	}
+	*fs = (*fs)[:0]
+	fieldsPool.Put(fs)
+}
+
+// getArgs returns a pooled slice of the args.
+func getArgs(args []any) *[]any {
+	as := argsPool.Get().(*[]any)
+	*as = append(*as, args...)
+	return as
+}
+
+// putArgs returns the slice to the pool, the args must not be used after that.
+func putArgs(as *[]any) {
+	if cap(*as) > maxPooledSlice {
+		return
+	}
+	for i := range *as {
+		(*as)[i] = nil
+	}
+	*as = (*as)[:0]
+	argsPool.Put(as)
+}
+
 // BeforeEncHook is the hook function to execute before encoding the log message.
+// The args are reused after the log message is written, they must not be retained.
 type BeforeEncHook func(string, []any) (string, []any)
 
 // AfterEncHook is the hook function to execute after encoding the log message.
@@ -42,17 +101,25 @@ type AfterEncHook func([]byte) []byte
 
 // logger represents a logger instance with configurable options
 type logger struct {
-	app       string          // the name of the application
-	level     Level           // the minimum level of logging to output
-	caller    EnableOp        // flag indicating whether to log the caller information
-	color     EnableOp        // flag indicating whether to use colorized output for levelTag on plain encoding
-	shortFile EnableOp        // flag indicating whether to use short file name in the log message
-	encType   EncodeType      // the encoding type to use for encoding the log message
-	timeFmt   string          // time format to use for logging
-	enc       EncodeFunc      // enc to use for encoding the log message
-	wr        Writer          // wr to output log to
-	beforeEnc []BeforeEncHook // beforeEnc to execute before encoding the log message
-	afterEnc  []AfterEncHook  // afterEnc to execute after encoding the log message
+	app        string                    // the name of the application
+	name       string                    // the hierarchical name of the logger created by Named
+	level      Level                     // the minimum level of logging to output
+	levelVar   *LevelVar                 // the shared minimum level of logging to output, it takes precedence over level
+	caller     EnableOp                  // flag indicating whether to log the caller information
+	color      EnableOp                  // flag indicating whether to use colorized output on plain and console encoding, detected by default
+	theme      *Theme                    // theme to color the output, the default theme of the encoding is used if it is nil
+	shortFile  EnableOp                  // flag indicating whether to use short file name in the log message
+	richError  EnableOp                  // flag indicating whether to write the error fields with their chain, verbose output and stack
+	stackOpts  *StackOptions             // options of the stack trace, the stack is written as a string if it is nil
+	callerOpts *CallerOptions            // options of the caller, the shortFile is used if it is nil
+	duplicates DuplicatePolicy           // policy for the fields with duplicate keys and the keys of the encoding
+	encType    EncodeType                // the encoding type to use for encoding the log message
+	timeFmt    string                    // time format to use for logging
+	enc        EncodeFunc                // enc to use for encoding the log message
+	wr         Writer                    // wr to output log to
+	beforeEnc  []BeforeEncHook           // beforeEnc to execute before encoding the log message
+	afterEnc   []AfterEncHook            // afterEnc to execute after encoding the log message
+	nameOpts   map[string][]LoggerOption // options of the loggers created by Named by their names
 }
 
 // NewLogger returns a new Logger instance with optional configurations
@@ -64,7 +131,7 @@ func NewLogger(opts ...LoggerOption) Logger {
 func newLogger(opts ...LoggerOption) *logger {
 	l := logger{
 		caller:    Enable,
-		color:     Enable,
+		color:     Default,
 		shortFile: Enable,
 		encType:   JSON,
 		timeFmt:   time.RFC3339,
@@ -86,10 +153,34 @@ func WithLoggerAppName(name string) LoggerOption {
 	}
 }
 
+// WithLoggerNameOptions sets the options applied to the loggers created by Named with the name, such as
+// "svc.db", the options of the parent names are applied first. It can set the levels of the components or
+// route their output to other writers.
+func WithLoggerNameOptions(name string, opts ...LoggerOption) LoggerOption {
+	name = EscapedString(name)
+	return func(l *logger) {
+		nameOpts := make(map[string][]LoggerOption, len(l.nameOpts)+1)
+		for k, v := range l.nameOpts {
+			nameOpts[k] = v
+		}
+		nameOpts[name] = append(nameOpts[name][:len(nameOpts[name]):len(nameOpts[name])], opts...)
+		l.nameOpts = nameOpts
+	}
+}
+
 // WithLoggerLevel sets the minimum logging level for the logger instance
 func WithLoggerLevel(level Level) LoggerOption {
 	return func(l *logger) {
 		l.level = level
+		l.levelVar = nil
+	}
+}
+
+// WithLoggerLevelVar sets the level variable for the logger instance, so that the minimum logging level
+// can be shared by many loggers and changed without creating new loggers.
+func WithLoggerLevelVar(v *LevelVar) LoggerOption {
+	return func(l *logger) {
+		l.levelVar = v
 	}
 }
 
@@ -104,7 +195,8 @@ func WithLoggerCaller(enable bool) LoggerOption {
 	}
 }
 
-// WithLoggerColor sets whether to use colorized output for levelTag on plain encoding, not supporting windows.
+// WithLoggerColor sets whether to use colorized output on plain and console encoding, overriding the detection
+// by default, which only uses colors when the output is an interactive terminal.
 func WithLoggerColor(enable bool) LoggerOption {
 	return func(l *logger) {
 		if enable {
@@ -115,6 +207,14 @@ func WithLoggerColor(enable bool) LoggerOption {
 	}
 }
 
+// WithLoggerTheme sets the theme to color the output on plain and console encoding, the plain encoding only
+// colors the levels, and the console encoding uses ThemeDefault if the theme is not set.
+func WithLoggerTheme(t *Theme) LoggerOption {
+	return func(l *logger) {
+		l.theme = t
+	}
+}
+
 // WithLoggerShortFile sets whether to use short file name in the log message
 func WithLoggerShortFile(enable bool) LoggerOption {
 	return func(l *logger) {
@@ -126,6 +226,43 @@ func WithLoggerShortFile(enable bool) LoggerOption {
 	}
 }
 
+// WithLoggerRichError sets whether to write the error fields with the chain of the wrapped errors, the %+v
+// output of the errors implementing fmt.Formatter and the stack of the errors implementing StackTracer,
+// as a nested object on JSON encoding and indented lines on plain and console encoding.
+func WithLoggerRichError(enable bool) LoggerOption {
+	return func(l *logger) {
+		if enable {
+			l.richError = Enable
+		} else {
+			l.richError = Disable
+		}
+	}
+}
+
+// WithLoggerCallerOptions sets the options of the caller, such as the form of the file, writing the function
+// and writing the caller as separate keys on JSON encoding, the short file option is ignored if they are set.
+func WithLoggerCallerOptions(o *CallerOptions) LoggerOption {
+	return func(l *logger) {
+		l.callerOpts = o
+	}
+}
+
+// WithLoggerStackOptions sets the options of the stack trace, such as writing the frames as an array of
+// objects on JSON encoding, dropping the runtime frames and trimming the files.
+func WithLoggerStackOptions(o *StackOptions) LoggerOption {
+	return func(l *logger) {
+		l.stackOpts = o
+	}
+}
+
+// WithLoggerDuplicatePolicy sets the policy for the fields with duplicate keys and the fields colliding with
+// the keys of the encoding, the first of the fields with the same key is written by default.
+func WithLoggerDuplicatePolicy(p DuplicatePolicy) LoggerOption {
+	return func(l *logger) {
+		l.duplicates = p
+	}
+}
+
 // WithLoggerTimeFormat sets the time format to use for logging
 func WithLoggerTimeFormat(format string) LoggerOption {
 	return func(l *logger) {
@@ -137,7 +274,7 @@ func WithLoggerTimeFormat(format string) LoggerOption {
 func WithLoggerEncode(e EncodeType) LoggerOption {
 	return func(l *logger) {
 		switch e {
-		case PLAIN, JSON:
+		case PLAIN, JSON, CONSOLE:
 			l.encType = e
 		default:
 			l.encType = JSON
@@ -265,7 +402,10 @@ func (l *logger) Tracew(msg string, fields ...Field) {
 }
 
 func (l *logger) IsEnabled(level Level) bool {
-	return level >= l.level
+	if l.levelVar != nil {
+		return level.Severity() >= l.levelVar.Level().Severity()
+	}
+	return level.Severity() >= l.level.Severity()
 }
 
 func (l *logger) log(r Record) {
@@ -277,213 +417,256 @@ func (l *logger) log(r Record) {
 
 	// force use logger app name, avoid using the app name from Record
 	r.App = l.app
+	r.Name = l.name
 
 	l.output(r)
 }
 
 func (l *logger) fatal(a ...any) {
+	as := getArgs(a)
 	l.output(Record{
 		Level:   FATAL,
-		MsgArgs: a,
+		MsgArgs: *as,
 		OsExit:  true,
 	})
+	putArgs(as)
 }
 
 func (l *logger) fatalf(format string, a ...any) {
+	as := getArgs(a)
 	l.output(Record{
 		Level:       FATAL,
 		MsgOrFormat: format,
-		MsgArgs:     a,
+		MsgArgs:     *as,
 		OsExit:      true,
 	})
+	putArgs(as)
 }
 
 func (l *logger) fatalw(msg string, fields ...Field) {
+	fs := getFields(fields, nil)
 	l.output(Record{
 		Level:       FATAL,
 		MsgOrFormat: msg,
-		Fields:      fields,
+		Fields:      *fs,
 		OsExit:      true,
 	})
+	putFields(fs)
 }
 
 func (l *logger) error(a ...any) {
 	if l.IsEnabled(ERROR) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:   ERROR,
-			MsgArgs: a,
+			MsgArgs: *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) errorf(format string, a ...any) {
 	if l.IsEnabled(ERROR) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       ERROR,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) errorw(msg string, fields ...Field) {
 	if l.IsEnabled(ERROR) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       ERROR,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (l *logger) warn(a ...any) {
 	if l.IsEnabled(WARN) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:   WARN,
-			MsgArgs: a,
+			MsgArgs: *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) warnf(format string, a ...any) {
 	if l.IsEnabled(WARN) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       WARN,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) warnw(msg string, fields ...Field) {
 	if l.IsEnabled(WARN) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       WARN,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (l *logger) notice(a ...any) {
 	if l.IsEnabled(NOTICE) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:   NOTICE,
-			MsgArgs: a,
+			MsgArgs: *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) noticef(format string, a ...any) {
 	if l.IsEnabled(NOTICE) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       NOTICE,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) noticew(msg string, fields ...Field) {
 	if l.IsEnabled(NOTICE) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       NOTICE,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (l *logger) info(a ...any) {
 	if l.IsEnabled(INFO) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:   INFO,
-			MsgArgs: a,
+			MsgArgs: *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) infof(format string, a ...any) {
 	if l.IsEnabled(INFO) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       INFO,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) infow(msg string, fields ...Field) {
 	if l.IsEnabled(INFO) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       INFO,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (l *logger) debug(a ...any) {
 	if l.IsEnabled(DEBUG) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:   DEBUG,
-			MsgArgs: a,
+			MsgArgs: *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) debugf(format string, a ...any) {
 	if l.IsEnabled(DEBUG) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       DEBUG,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) debugw(msg string, fields ...Field) {
 	if l.IsEnabled(DEBUG) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       DEBUG,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
 func (l *logger) trace(a ...any) {
 	if l.IsEnabled(TRACE) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:     TRACE,
 			Stack:     Enable,
 			StackSize: defStackSize,
-			MsgArgs:   a,
+			MsgArgs:   *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) tracef(format string, a ...any) {
 	if l.IsEnabled(TRACE) {
+		as := getArgs(a)
 		l.output(Record{
 			Level:       TRACE,
 			Stack:       Enable,
 			StackSize:   defStackSize,
 			MsgOrFormat: format,
-			MsgArgs:     a,
+			MsgArgs:     *as,
 		})
+		putArgs(as)
 	}
 }
 
 func (l *logger) tracew(msg string, fields ...Field) {
 	if l.IsEnabled(TRACE) {
+		fs := getFields(fields, nil)
 		l.output(Record{
 			Level:       TRACE,
 			Stack:       Enable,
 			StackSize:   defStackSize,
 			MsgOrFormat: msg,
-			Fields:      fields,
+			Fields:      *fs,
 		})
+		putFields(fs)
 	}
 }
 
@@ -491,6 +674,10 @@ func (l *logger) buildFields(fields ...Field) []Field {
 	return fields
 }
 
+func (l *logger) duplicatePolicy() DuplicatePolicy {
+	return l.duplicates
+}
+
 func (l *logger) output(r Record) {
 	if r.Caller == Default {
 		r.Caller = l.caller
@@ -500,6 +687,22 @@ func (l *logger) output(r Record) {
 		r.ShortFile = l.shortFile
 	}
 
+	if r.RichError == Default {
+		r.RichError = l.richError
+	}
+
+	if r.StackOpts == nil {
+		r.StackOpts = l.stackOpts
+	}
+
+	if r.CallerOpts == nil {
+		r.CallerOpts = l.callerOpts
+	}
+
+	if r.Duplicates == DuplicateDefault {
+		r.Duplicates = l.duplicates
+	}
+
 	if r.CallerSkip <= 0 {
 		r.CallerSkip = defCallerSkip
 	}
@@ -518,8 +721,21 @@ func (l *logger) output(r Record) {
 	if r.App == "" {
 		r.App = l.app
 	}
+	if r.Name == "" {
+		r.Name = l.name
+	}
 	r.TimeFmt = l.timeFmt
 
+	// the lazy values are evaluated once for the record, as the fields may be encoded more than once, and
+	// the groups are flattened for the plain and console encoding, the other encodings write them as nested
+	// objects or flatten them by themselves.
+	var resolved *[]Field
+	if flatten := l.encType == PLAIN || l.encType == CONSOLE; hasLazyFields(r.Fields, flatten) {
+		resolved = fieldsPool.Get().(*[]Field)
+		*resolved = resolveFields(*resolved, r.Fields, "", flatten)
+		r.Fields = *resolved
+	}
+
 	for _, f := range l.beforeEnc {
 		r.MsgOrFormat, r.MsgArgs = f(r.MsgOrFormat, r.MsgArgs)
 	}
@@ -528,7 +744,9 @@ func (l *logger) output(r Record) {
 
 	switch l.encType {
 	case PLAIN:
-		plainEncode(r, buf, l.color.IsOpen())
+		plainEncode(r, buf, l.colorTheme(r.Level, plainTheme))
+	case CONSOLE:
+		consoleEncode(r, buf, l.colorTheme(r.Level, ThemeDefault))
 	case -1:
 		l.enc(r, buf)
 	default:
@@ -542,24 +760,50 @@ func (l *logger) output(r Record) {
 	_, _ = l.wr.Write(r.Level, data)
 
 	putBuf(buf)
+	if resolved != nil {
+		putFields(resolved)
+	}
 
 	if r.OsExit {
 		os.Exit(1)
 	}
 }
 
+// colorTheme returns the theme to color the output for the level, or nil if the output is not colorized.
+// The color is detected from the writer if it is not set explicitly, and the theme is def if it is not set.
+func (l *logger) colorTheme(level Level, def *Theme) *Theme {
+	if !colorSupported() {
+		return nil
+	}
+	if l.color == Default && !writerColor(l.wr, level) || l.color == Disable {
+		return nil
+	}
+	if l.theme != nil {
+		return l.theme
+	}
+	return def
+}
+
 func (l *logger) clone() *logger {
 	return &logger{
-		app:       l.app,
-		level:     l.level,
-		caller:    l.caller,
-		color:     l.color,
-		shortFile: l.shortFile,
-		encType:   l.encType,
-		timeFmt:   l.timeFmt,
-		enc:       l.enc,
-		wr:        l.wr,
-		afterEnc:  l.afterEnc,
-		beforeEnc: l.beforeEnc,
+		app:        l.app,
+		name:       l.name,
+		level:      l.level,
+		levelVar:   l.levelVar,
+		caller:     l.caller,
+		color:      l.color,
+		theme:      l.theme,
+		shortFile:  l.shortFile,
+		richError:  l.richError,
+		stackOpts:  l.stackOpts,
+		callerOpts: l.callerOpts,
+		duplicates: l.duplicates,
+		encType:    l.encType,
+		timeFmt:    l.timeFmt,
+		enc:        l.enc,
+		wr:         l.wr,
+		afterEnc:   l.afterEnc,
+		beforeEnc:  l.beforeEnc,
+		nameOpts:   l.nameOpts,
 	}
 }
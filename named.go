@@ -0,0 +1,52 @@
+package olog
+
+// Named creates a new logger named by the name of the logger joined with the name by ".", such as
+// "svc.db.pool", the name is written as the "logger" key. The fields and namespaces of the logger are kept,
+// and the options set by WithLoggerNameOptions for the new name and the names between it and the name of
+// the logger are applied in order, so that the named loggers can have their own levels or writers.
+func Named(logger Logger, name string) Logger {
+	if name == "" {
+		return logger
+	}
+	return logger.named(EscapedString(name))
+}
+
+// joinName joins the name of the logger and the name of the child by ".".
+func joinName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func (l *logger) named(name string) Logger {
+	// apply the options of the names after the name of the logger, such as "svc.db" and "svc.db.pool" for
+	// the name "db.pool" of the logger "svc".
+	return l.withName(joinName(l.name, name), len(l.name)+1)
+}
+
+// withName returns a copy of the logger with the name, the options of the names of the name starting at
+// the index from are applied in order.
+func (l *logger) withName(name string, from int) *logger {
+	c := l.clone()
+	c.name = name
+	for i := from; i <= len(name); i++ {
+		if i == len(name) || name[i] == '.' {
+			for _, opt := range l.nameOpts[name[:i]] {
+				opt(c)
+			}
+		}
+	}
+	return c
+}
+
+func (c *ctxLogger) named(name string) Logger {
+	n := *c
+	n.Logger = c.Logger.named(name)
+	return &n
+}
+
+func (d DynamicLogger) named(name string) Logger {
+	d.name = joinName(d.name, name)
+	return d
+}
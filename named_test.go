@@ -0,0 +1,142 @@
+package olog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerAppName("app"))
+	svc := Named(base, "svc")
+
+	tests := []struct {
+		log  func()
+		want string
+	}{
+		{
+			log:  func() { svc.Info("hello") },
+			want: `"level":"info","app":"app","logger":"svc","content":"hello"}`,
+		},
+		{
+			log:  func() { Named(svc, "db").Infow("hello", Field{Key: "logger", Value: "x"}) },
+			want: `"level":"info","app":"app","logger":"svc.db","content":"hello"}`,
+		},
+		{
+			log: func() {
+				logger := WithFields(WithContext(Named(svc, "db"), context.Background()), Field{Key: "id", Value: 1})
+				Named(logger, "pool").Infow("hello", Field{Key: "size", Value: 2})
+			},
+			want: `"logger":"svc.db.pool","content":"hello","size":2,"id":1}`,
+		},
+		{
+			log:  func() { Named(WithNamespace(svc, "http"), "client").Infow("hello", Field{Key: "status", Value: 200}) },
+			want: `"logger":"svc.client","content":"hello","http":{"status":200}}`,
+		},
+		{
+			log:  func() { Named(svc, "").Info("hello") },
+			want: `"logger":"svc","content":"hello"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log()
+		if !strings.HasSuffix(buf.String(), tt.want+"\n") {
+			t.Errorf("output = %s, want suffix %s", buf.String(), tt.want)
+		}
+	}
+
+	buf.Reset()
+	Named(NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncode(PLAIN)), "svc").Info("hello")
+	if want := "\tinfo\tsvc\thello\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %q, want suffix %q", buf.String(), want)
+	}
+}
+
+func TestNameOptions(t *testing.T) {
+	var buf, audit bytes.Buffer
+	base := NewLogger(
+		WithLoggerWriter(NewWriter(&buf)),
+		WithLoggerCaller(false),
+		WithLoggerLevel(INFO),
+		WithLoggerNameOptions("svc.db", WithLoggerLevel(DEBUG)),
+		WithLoggerNameOptions("svc.db.pool", WithLoggerLevel(WARN)),
+		WithLoggerNameOptions("audit", WithLoggerWriter(NewWriter(&audit))),
+	)
+
+	Named(base, "svc").Debug("svc")
+	Named(Named(base, "svc"), "db").Debug("db")
+	Named(base, "svc.db.pool").Info("pool")
+	Named(base, "svc.db.pool").Warn("pool")
+	Named(base, "audit").Info("audit")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"logger":"svc.db","content":"db"`) ||
+		!strings.Contains(lines[1], `"logger":"svc.db.pool","content":"pool"`) {
+		t.Errorf("output = %s", buf.String())
+	}
+	if !strings.Contains(audit.String(), `"logger":"audit","content":"audit"`) {
+		t.Errorf("audit output = %s", audit.String())
+	}
+}
+
+func TestNameOptionsDynamic(t *testing.T) {
+	defer setDefLogger(getDefLogger())
+	var buf bytes.Buffer
+	setDefLogger(newLogger())
+	SetLoggerOptions(
+		WithLoggerWriter(NewWriter(&buf)),
+		WithLoggerCaller(false),
+		WithLoggerLevel(INFO),
+		WithLoggerNameOptions("svc.db", WithLoggerLevel(DEBUG)),
+	)
+
+	var d DynamicLogger
+	Named(d, "svc").Debug("svc")
+	Named(Named(d, "svc"), "db").Debug("db")
+	WithFields(Named(d, "svc.db"), Field{Key: "id", Value: 1}).Debugw("ctx")
+	if !Named(d, "svc.db").IsEnabled(DEBUG) || Named(d, "svc").IsEnabled(DEBUG) {
+		t.Error("IsEnabled(DEBUG) does not apply the name options")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"logger":"svc.db","content":"db"`) ||
+		!strings.Contains(lines[1], `"logger":"svc.db","content":"ctx","id":1`) {
+		t.Errorf("output = %s", buf.String())
+	}
+}
+
+func TestNameOptionsReused(t *testing.T) {
+	var buf bytes.Buffer
+	opt := WithLoggerNameOptions(`svc"db`, WithLoggerLevel(DEBUG))
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerLevel(INFO), opt)
+		Named(logger, `svc"db`).Debug("db")
+		if !strings.Contains(buf.String(), `"logger":"svc\"db","content":"db"`) {
+			t.Errorf("logger %d output = %s", i, buf.String())
+		}
+	}
+}
+
+func TestNamedEcs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncodeFunc(EcsEncode))
+	Named(logger, "svc").Info("hello")
+
+	var m struct {
+		Log struct {
+			Logger string `json:"logger"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+	if m.Log.Logger != "svc" {
+		t.Errorf("log.logger = %q, want svc", m.Log.Logger)
+	}
+}
@@ -0,0 +1,102 @@
+package olog
+
+// Group is the value of a field nesting the fields, it is written as a nested object on the JSON, ECS and Loki
+// encodings, and as the fields with the keys prefixed by the key of the group and "." on the other encodings.
+// The groups are passed to the custom EncodeFunc unflattened.
+type Group []Field
+
+// namespace is the namespace of a ctxLogger, the fields of the logger and its logging calls are nested in
+// the group of the namespace.
+type namespace struct {
+	parent Logger  // parent is the logger the namespace is created from, the group is one of its fields
+	name   string  // name is the key of the group
+	fields []Field // fields is the fields of the group added by WithFields and WithContext
+	last   []Field // last is the fields of the group in scope order for DuplicateKeepLast
+}
+
+// WithNamespace creates a new logger whose fields, including the ones added by WithFields and WithContext
+// later and the ones of the logging calls, are nested under the name. The namespaces can be nested, like
+// the WithGroup of slog, the empty groups are not written.
+func WithNamespace(logger Logger, name string) Logger {
+	if name == "" {
+		return logger
+	}
+
+	ns := &namespace{parent: logger, name: name}
+	return &ctxLogger{
+		Logger: logger,
+		fields: ns.buildFields(nil),
+		last:   ns.lastFields(nil),
+		ns:     ns,
+	}
+}
+
+// with returns a copy of the namespace with the fields added in front.
+func (n *namespace) with(fields []Field) *namespace {
+	if len(fields) == 0 {
+		return n
+	}
+
+	ns := *n
+	ns.fields = make([]Field, 0, len(fields)+len(n.fields))
+	ns.fields = append(append(ns.fields, fields...), n.fields...)
+	ns.last = make([]Field, 0, len(fields)+len(n.last))
+	ns.last = append(append(ns.last, n.last...), fields...)
+	return &ns
+}
+
+// buildFields returns the fields of the parent with the group of the fields followed by the fields of the
+// namespace.
+func (n *namespace) buildFields(fields []Field) []Field {
+	if len(fields)+len(n.fields) == 0 {
+		return n.parent.buildFields()
+	}
+
+	group := make(Group, 0, len(fields)+len(n.fields))
+	group = append(append(group, fields...), n.fields...)
+	return n.parent.buildFields(Field{Key: n.name, Value: group})
+}
+
+// lastFields returns the fields of the parent in scope order with the group of the fields of the namespace
+// followed by the fields.
+func (n *namespace) lastFields(fields []Field) []Field {
+	if len(fields)+len(n.last) == 0 {
+		return lastFields(n.parent, nil)
+	}
+
+	group := make(Group, 0, len(fields)+len(n.last))
+	group = append(append(group, n.last...), fields...)
+	return lastFields(n.parent, []Field{{Key: n.name, Value: group}})
+}
+
+// FlattenGroups returns the fields with the groups flattened to the fields with the keys prefixed by the key
+// of the group and ".", such as "req.id", for the custom EncodeFunc without nested objects. The fields are
+// returned unchanged if there are no groups.
+func FlattenGroups(fields []Field) []Field {
+	if !hasGroups(fields) {
+		return fields
+	}
+	return resolveFields(make([]Field, 0, len(fields)), fields, "", true)
+}
+
+// flattenGroups returns a pooled slice of the fields with the groups flattened to the fields with the keys
+// prefixed by the key of the group and ".", for the encodings without nested objects.
+func flattenGroups(fields []Field) *[]Field {
+	fs := fieldsPool.Get().(*[]Field)
+	if hasGroups(fields) {
+		*fs = resolveFields(*fs, fields, "", true)
+	} else {
+		*fs = append(*fs, fields...)
+	}
+	return fs
+}
+
+// hasGroups reports whether any of the fields is a Group.
+func hasGroups(fields []Field) bool {
+	for _, field := range fields {
+		if _, ok := field.Value.(Group); ok {
+			return true
+		}
+	}
+	return false
+}
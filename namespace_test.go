@@ -0,0 +1,94 @@
+package olog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithNamespace(t *testing.T) {
+	var buf bytes.Buffer
+	base := WithFields(NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false)), Field{Key: "app_id", Value: 1})
+	http := WithFields(WithNamespace(base, "http"), Field{Key: "method", Value: "GET"})
+
+	tests := []struct {
+		log  func()
+		want string
+	}{
+		{
+			log:  func() { http.Infow("request", Field{Key: "status", Value: 200}) },
+			want: `"content":"request","http":{"status":200,"method":"GET"},"app_id":1}`,
+		},
+		{
+			log:  func() { http.Info("request") },
+			want: `"content":"request","http":{"method":"GET"},"app_id":1}`,
+		},
+		{
+			log:  func() { WithNamespace(http, "req").Info("request") },
+			want: `"content":"request","http":{"method":"GET"},"app_id":1}`,
+		},
+		{
+			log: func() {
+				req := WithContext(WithNamespace(http, "req"), context.Background())
+				WithFields(req, Field{Key: "id", Value: "x"}).Warnw("request", Field{Key: "level", Value: 1})
+			},
+			want: `"content":"request","http":{"req":{"level":1,"id":"x"},"method":"GET"},"app_id":1}`,
+		},
+		{
+			log: func() {
+				http.Log(Record{Level: INFO, MsgOrFormat: "request", Fields: []Field{{Key: "status", Value: 404}}})
+			},
+			want: `"content":"request","http":{"status":404,"method":"GET"},"app_id":1}`,
+		},
+		{
+			log:  func() { WithNamespace(base, "empty").Info("request") },
+			want: `"content":"request","app_id":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log()
+		if !strings.HasSuffix(buf.String(), tt.want+"\n") {
+			t.Errorf("output = %s, want suffix %s", buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWithNamespacePlain(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncode(PLAIN))
+	logger = WithFields(WithNamespace(WithNamespace(logger, "http"), "req"), Field{Key: "id", Value: "x"})
+	logger.Infow("request", Field{Key: "status", Value: 200})
+
+	if want := "\trequest\thttp.req.status=200\thttp.req.id=x\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("output = %q, want suffix %q", buf.String(), want)
+	}
+}
+
+func TestWithNamespaceEncodeFunc(t *testing.T) {
+	tests := []struct {
+		encode EncodeFunc
+		want   string
+	}{
+		{encode: EcsEncode, want: `,"http":{"status":200,"method":"GET"},"app_id":1}`},
+		{encode: GelfEncode, want: `,"_http.status":200,"_http.method":"GET","_app_id":1}`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncodeFunc(tt.encode))
+		logger = WithFields(WithNamespace(WithFields(logger, Field{Key: "app_id", Value: 1}), "http"), Field{Key: "method", Value: "GET"})
+		logger.Infow("request", Field{Key: "status", Value: 200})
+
+		if !strings.HasSuffix(buf.String(), tt.want+"\n") {
+			t.Errorf("output = %s, want suffix %s", buf.String(), tt.want)
+		}
+	}
+
+	fields := FlattenGroups([]Field{{Key: "http", Value: Group{{Key: "req", Value: Group{{Key: "id", Value: 1}}}}}})
+	if len(fields) != 1 || fields[0].Key != "http.req.id" {
+		t.Errorf("FlattenGroups = %v", fields)
+	}
+}
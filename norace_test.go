@@ -0,0 +1,5 @@
+//go:build !race
+
+package olog
+
+const raceEnabled = false
@@ -0,0 +1,6 @@
+//go:build race
+
+package olog
+
+// raceEnabled reports whether the tests are built with the race detector, which makes the allocations.
+const raceEnabled = true
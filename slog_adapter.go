@@ -7,15 +7,36 @@ import (
 	"log/slog"
 )
 
-var slogLevel2Level = map[slog.Level]Level{
-	slog.LevelDebug: DEBUG,
-	slog.LevelInfo:  INFO,
-	slog.LevelWarn:  WARN,
-	slog.LevelError: ERROR,
+// slogLevelAnchors pairs the slog levels with the corresponding levels, the slog levels between
+// and beyond them are mapped linearly to severities, so that they can match custom levels.
+var slogLevelAnchors = [...]struct {
+	slog  slog.Level
+	level Level
+}{
+	{slog.LevelDebug, DEBUG},
+	{slog.LevelInfo, INFO},
+	{slog.LevelWarn, WARN},
+	{slog.LevelError, ERROR},
+}
+
+// slogLevelToLevel converts the slog level to the registered Level with the nearest lower severity,
+// the result is always below FATAL.
+func slogLevelToLevel(sl slog.Level) Level {
+	i := 1
+	for i < len(slogLevelAnchors)-1 && sl > slogLevelAnchors[i].slog {
+		i++
+	}
+
+	lo, hi := slogLevelAnchors[i-1], slogLevelAnchors[i]
+	loSeverity, hiSeverity := lo.level.Severity(), hi.level.Severity()
+	severity := loSeverity + int(sl-lo.slog)*(hiSeverity-loSeverity)/int(hi.slog-lo.slog)
+	if severity >= FATAL.Severity() {
+		severity = FATAL.Severity() - 1
+	}
+	return levelBySeverity(severity)
 }
 
 type SlogHandler struct {
-	prefix    string
 	logger    Logger
 	ctxHandle CtxHandle
 }
@@ -35,30 +56,38 @@ func NewSlogHandler(logger Logger, handles ...CtxHandle) SlogHandler {
 }
 
 func (s SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return s.logger.IsEnabled(slogLevel2Level[level])
+	return s.logger.IsEnabled(slogLevelToLevel(level))
 }
 
+// Handle handles the record, the attrs of the record are nested in the groups of the handler, and the fields
+// of the context handle are kept at the top level.
 func (s SlogHandler) Handle(ctx context.Context, record slog.Record) error {
-	fields := s.ctxHandle(ctx)
-
 	r := Record{
-		Level:       slogLevel2Level[record.Level],
+		Level:       slogLevelToLevel(record.Level),
 		CallerSkip:  2,
 		MsgOrFormat: record.Message,
-		Fields:      s.logger.buildFields(fields...),
 		Time:        record.Time,
 	}
 
-	attrLen := record.NumAttrs()
-	if attrLen > 0 {
-		fields = make([]Field, 0, len(r.Fields)+attrLen)
+	var fields []Field
+	if attrLen := record.NumAttrs(); attrLen > 0 {
+		fields = make([]Field, 0, attrLen)
 		record.Attrs(func(attr slog.Attr) bool {
-			fields = addAttrsToFields(fields, s.prefix, attr)
+			fields = addAttrsToFields(fields, attr)
 			return true
 		})
-
-		fields = append(fields, r.Fields...)
-		r.Fields = fields
+	}
+	ctxFields := s.ctxHandle(ctx)
+	if s.logger.duplicatePolicy() == DuplicateKeepLast {
+		r.Fields = lastFields(s.logger, fields)
+		if len(ctxFields) > 0 {
+			r.Fields = append(r.Fields[:len(r.Fields):len(r.Fields)], ctxFields...)
+		}
+	} else {
+		r.Fields = s.logger.buildFields(fields...)
+		if len(ctxFields) > 0 {
+			r.Fields = append(ctxFields, r.Fields...)
+		}
 	}
 
 	s.logger.log(r)
@@ -68,34 +97,41 @@ func (s SlogHandler) Handle(ctx context.Context, record slog.Record) error {
 
 func (s SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	fields := make([]Field, 0, len(attrs))
-	fields = addAttrsToFields(fields, s.prefix, attrs...)
+	fields = addAttrsToFields(fields, attrs...)
 
 	return SlogHandler{
-		prefix: s.prefix,
-		logger: &ctxLogger{
-			Logger: s.logger,
-			fields: fields,
-		},
+		logger:    WithFields(s.logger, fields...),
 		ctxHandle: s.ctxHandle,
 	}
 }
 
+// WithGroup returns a handler whose attrs are nested in the group, which is written as a nested object on
+// JSON encoding by WithNamespace.
 func (s SlogHandler) WithGroup(name string) slog.Handler {
 	return SlogHandler{
-		prefix:    attrKeyJoin(s.prefix, name),
-		logger:    s.logger,
+		logger:    WithNamespace(s.logger, name),
 		ctxHandle: s.ctxHandle,
 	}
 }
 
-func addAttrsToFields(fields []Field, prefix string, attrs ...slog.Attr) []Field {
+// addAttrsToFields appends the attrs to the fields, the groups are added as Group, the groups with empty
+// keys are inlined and the empty groups are dropped. The slog.LogValuer values are resolved when the record
+// is encoded.
+func addAttrsToFields(fields []Field, attrs ...slog.Attr) []Field {
 	for _, attr := range attrs {
-		key := attrKeyJoin(prefix, attr.Key)
 		if attr.Value.Kind() == slog.KindGroup {
-			fields = addAttrsToFields(fields, key, attr.Value.Group()...)
+			group := attr.Value.Group()
+			if attr.Key == "" {
+				fields = addAttrsToFields(fields, group...)
+			} else if len(group) > 0 {
+				fields = append(fields, Field{
+					Key:   attr.Key,
+					Value: Group(addAttrsToFields(make([]Field, 0, len(group)), group...)),
+				})
+			}
 		} else {
 			fields = append(fields, Field{
-				Key:   key,
+				Key:   attr.Key,
 				Value: attr.Value.Any(),
 			})
 		}
@@ -103,11 +139,3 @@ func addAttrsToFields(fields []Field, prefix string, attrs ...slog.Attr) []Field
 
 	return fields
 }
-
-func attrKeyJoin(prefix, key string) string {
-	if prefix == "" {
-		return key
-	}
-
-	return prefix + "." + key
-}
@@ -69,7 +69,7 @@ func TestSlogHandlerWithAttrs(t *testing.T) {
 	handler = NewSlogHandler(NewLogger(
 		WithLoggerWriter(NewWriter(io.Discard)),
 		WithLoggerEncodeFunc(func(record Record, buffer *encoder.Buffer) {
-			vfn2(record.Fields, t)
+			vfn2(FlattenGroups(record.Fields), t)
 		}),
 	))
 	logger = slog.New(handler)
@@ -187,3 +187,56 @@ func BenchmarkSlogHandler(b *testing.B) {
 		}
 	})
 }
+
+func TestSlogLevelToLevel(t *testing.T) {
+	tests := []struct {
+		sl    slog.Level
+		level Level
+	}{
+		{sl: slog.LevelDebug - 8, level: TRACE},
+		{sl: slog.LevelDebug - 4, level: TRACE},
+		{sl: slog.LevelDebug, level: DEBUG},
+		{sl: slog.LevelInfo, level: INFO},
+		{sl: slog.LevelInfo + 2, level: NOTICE},
+		{sl: slog.LevelWarn, level: WARN},
+		{sl: slog.LevelError, level: ERROR},
+		{sl: slog.LevelError + 2, level: ERROR},
+		{sl: slog.LevelError + 8, level: ERROR},
+	}
+
+	for _, tt := range tests {
+		if level := slogLevelToLevel(tt.sl); level != tt.level {
+			t.Errorf("slogLevelToLevel(%s) = %s, want %s", tt.sl, level, tt.level)
+		}
+	}
+}
+
+func TestSlogHandlerLevelVar(t *testing.T) {
+	v := NewLevelVar(ERROR)
+	handler := NewSlogHandler(NewLogger(WithLoggerLevelVar(v), WithLoggerWriter(NewWriter(io.Discard))))
+
+	ctx := context.Background()
+	if handler.Enabled(ctx, slog.LevelWarn) {
+		t.Error("slog.LevelWarn is enabled with level error")
+	}
+
+	v.Set(WARN)
+	if !handler.Enabled(ctx, slog.LevelWarn) {
+		t.Error("slog.LevelWarn is disabled with level warn")
+	}
+	if !handler.WithGroup("g").Enabled(ctx, slog.LevelWarn) {
+		t.Error("slog.LevelWarn is disabled with level warn in group")
+	}
+}
+
+func TestSlogHandlerWithGroup(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false))))
+	logger.With("app_id", 1).WithGroup("http").With("method", "GET").WithGroup("req").
+		Info("request", "status", 200, slog.Group("user", "id", 7), slog.Group("empty"))
+
+	want := `"content":"request","http":{"req":{"status":200,"user":{"id":7}},"method":"GET"},"app_id":1}` + "\n"
+	if !bytes.HasSuffix(buf.Bytes(), []byte(want)) {
+		t.Errorf("output = %s, want suffix %s", buf.String(), want)
+	}
+}
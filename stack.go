@@ -0,0 +1,141 @@
+package olog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// StackOptions configures the stack written by the encodings. The stack is written as a string of all the
+// frames by default.
+type StackOptions struct {
+	Frames       bool     // Frames writes the stack as an array of {function, file, line} objects on JSON encoding
+	SkipRuntime  bool     // SkipRuntime drops the frames of the runtime and testing packages
+	TrimPaths    bool     // TrimPaths trims the GOROOT and module cache directories from the files
+	TrimPrefixes []string // TrimPrefixes are trimmed from the files, such as the directory of the main module
+	Goroutine    bool     // Goroutine writes the id of the goroutine once before the frames, as "goroutine N"
+}
+
+// stackTrimPaths is the GOROOT source and module cache directories trimmed by StackOptions.TrimPaths.
+var stackTrimPaths = func() []string {
+	var paths []string
+	if root := runtime.GOROOT(); root != "" {
+		paths = append(paths, filepath.ToSlash(root)+"/src/")
+	}
+	for _, p := range filepath.SplitList(stackGOPATH()) {
+		paths = append(paths, filepath.ToSlash(p)+"/pkg/mod/")
+	}
+	return paths
+}()
+
+// stackGOPATH returns the GOPATH, which is $HOME/go if it is not set. The go/build package is not used to
+// get it, so that the go parser is not linked into the programs.
+func stackGOPATH() string {
+	if gopath := os.Getenv("GOPATH"); gopath != "" {
+		return gopath
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, "go")
+	}
+	return ""
+}
+
+// keepFrame reports whether the frame is written in the stack.
+func (o *StackOptions) keepFrame(frame runtime.Frame) bool {
+	if o == nil || !o.SkipRuntime {
+		return true
+	}
+	return !strings.HasPrefix(frame.Function, "runtime.") && !strings.HasPrefix(frame.Function, "testing.")
+}
+
+// file returns the file of the frame with the prefixes trimmed.
+func (o *StackOptions) file(file string) string {
+	if o == nil {
+		return file
+	}
+	for _, prefix := range o.TrimPrefixes {
+		if strings.HasPrefix(file, prefix) {
+			return file[len(prefix):]
+		}
+	}
+	if o.TrimPaths {
+		for _, prefix := range stackTrimPaths {
+			if strings.HasPrefix(file, prefix) {
+				return file[len(prefix):]
+			}
+		}
+	}
+	return file
+}
+
+// eachFrame calls fn with the function, the file with the prefixes trimmed and the line of the frames kept
+// by the options, starting from the frame.
+func (o *StackOptions) eachFrame(frame runtime.Frame, frames *runtime.Frames, more bool,
+	fn func(function, file string, line int)) {
+	for frame.PC != 0 {
+		if o.keepFrame(frame) {
+			fn(frame.Function, o.file(frame.File), frame.Line)
+		}
+
+		if !more {
+			break
+		}
+		frame, more = frames.Next()
+	}
+}
+
+// WriteStackText writes the stack as text to the buffer, which is "goroutine N" if it is enabled, followed by
+// the function and the "\tfile:line" of the frames kept by the options, each on its own line. The lines are
+// separated by "\n", the first frame is also prefixed by it if lead is true, and the text is JSON escaped if
+// escape is true. The frames are the ones returned by Record.FirstFrame.
+func WriteStackText(buf *encoder.Buffer, opts *StackOptions, frame runtime.Frame, frames *runtime.Frames,
+	more, lead, escape bool) {
+	enc := encoder.JsonEncoder{Buffer: buf}
+	newline, tab := "\n", "\t"
+	if escape {
+		newline, tab = `\n`, `\t`
+	}
+	writeString := func(s string) {
+		if escape {
+			enc.WriteEscapedString(s)
+		} else {
+			_, _ = buf.WriteString(s)
+		}
+	}
+
+	if opts != nil && opts.Goroutine {
+		lead = true
+		_, _ = buf.WriteString("goroutine ")
+		buf.WriteUint64(goroutineID())
+	}
+
+	opts.eachFrame(frame, frames, more, func(function, file string, line int) {
+		if lead {
+			_, _ = buf.WriteString(newline)
+		}
+		lead = true
+		writeString(function)
+		_, _ = buf.WriteString(newline)
+		_, _ = buf.WriteString(tab)
+		writeString(file)
+		_ = buf.WriteByte(':')
+		buf.WriteInt64(int64(line))
+	})
+}
+
+// goroutineID returns the id of the current goroutine parsed from its stack header, or 0 if it is unknown.
+func goroutineID() uint64 {
+	var b [64]byte
+	s := b[:runtime.Stack(b[:], false)]
+	s = bytes.TrimPrefix(s, []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i > 0 {
+		id, _ := strconv.ParseUint(string(s[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
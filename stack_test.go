@@ -0,0 +1,132 @@
+package olog
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStackFrames(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := file[:strings.LastIndexByte(file, '/')+1]
+
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerStackOptions(&StackOptions{
+		Frames:       true,
+		SkipRuntime:  true,
+		TrimPrefixes: []string{dir},
+		Goroutine:    true,
+	}))
+	logger.Log(Record{Level: ERROR, Stack: Enable, StackSize: 10, MsgOrFormat: "failed"})
+
+	var m struct {
+		Goroutine uint64 `json:"goroutine"`
+		Stack     []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"stack"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+
+	if m.Goroutine == 0 || m.Goroutine != goroutineID() {
+		t.Errorf("goroutine = %d, want %d", m.Goroutine, goroutineID())
+	}
+	if len(m.Stack) != 1 {
+		t.Fatalf("stack = %+v, want the frame of the test only", m.Stack)
+	}
+	if f := m.Stack[0]; f.Function != "github.com/welllog/olog.TestStackFrames" || f.File != "stack_test.go" || f.Line != 23 {
+		t.Errorf("frame = %+v", f)
+	}
+}
+
+func TestStackString(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerStackOptions(&StackOptions{
+		SkipRuntime: true,
+		Goroutine:   true,
+	}))
+	logger.Log(Record{Level: ERROR, Stack: Enable, StackSize: 10, MsgOrFormat: "failed"})
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.Bytes(), err)
+	}
+	stack, _ := m["stack"].(string)
+	if !strings.HasPrefix(stack, "goroutine ") || !strings.Contains(stack, "\ngithub.com/welllog/olog.TestStackString\n\t") ||
+		strings.Contains(stack, "testing.tRunner") || strings.Contains(stack, "runtime.goexit") {
+		t.Errorf("stack = %q", stack)
+	}
+}
+
+func TestStackOptionsFile(t *testing.T) {
+	o := &StackOptions{TrimPaths: true, TrimPrefixes: []string{"/app/"}}
+	if got := o.file("/app/main.go"); got != "main.go" {
+		t.Errorf("file(/app/main.go) = %q", got)
+	}
+	if len(stackTrimPaths) > 0 {
+		if got := o.file(stackTrimPaths[0] + "net/http/server.go"); got != "net/http/server.go" {
+			t.Errorf("file = %q, want net/http/server.go", got)
+		}
+	}
+	if got := (*StackOptions)(nil).file("/app/main.go"); got != "/app/main.go" {
+		t.Errorf("nil file = %q", got)
+	}
+}
+
+func TestStackOptionsEncodeFuncs(t *testing.T) {
+	fluent := NewFluentWriter("tcp", "127.0.0.1:1")
+	defer fluent.Close()
+
+	encodes := map[string]EncodeFunc{
+		"ecs":    EcsEncode,
+		"gelf":   GelfEncode,
+		"fluent": fluent.Encode,
+	}
+	for name, encode := range encodes {
+		testStackOptionsEncode(t, name, encode)
+	}
+}
+
+// testStackOptionsEncode checks the stack written by the EncodeFunc applies the StackOptions.
+func testStackOptionsEncode(t *testing.T, name string, encode EncodeFunc) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := file[:strings.LastIndexByte(file, '/')+1]
+
+	var buf bytes.Buffer
+	logger := NewLogger(WithLoggerWriter(NewWriter(&buf)), WithLoggerCaller(false), WithLoggerEncodeFunc(encode),
+		WithLoggerStackOptions(&StackOptions{SkipRuntime: true, TrimPrefixes: []string{dir}, Goroutine: true}))
+	logger.Log(Record{Level: ERROR, Stack: Enable, StackSize: 10, MsgOrFormat: "failed"})
+
+	out := buf.String()
+	if !strings.Contains(out, "goroutine ") {
+		t.Errorf("%s: goroutine is not written in %q", name, out)
+	}
+	if !strings.Contains(out, "\tstack_test.go:") && !strings.Contains(out, `\tstack_test.go:`) {
+		t.Errorf("%s: file is not trimmed in %q", name, out)
+	}
+	if strings.Contains(out, "testing.tRunner") {
+		t.Errorf("%s: runtime frames are not skipped in %q", name, out)
+	}
+}
+
+func TestStackGOPATH(t *testing.T) {
+	t.Setenv("GOPATH", "/go")
+	if got := stackGOPATH(); got != "/go" {
+		t.Errorf("stackGOPATH() = %q, want /go", got)
+	}
+
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("the home directory is not $HOME")
+	}
+	t.Setenv("GOPATH", "")
+	t.Setenv("HOME", "/home/user")
+	if got := stackGOPATH(); got != filepath.Join("/home/user", "go") {
+		t.Errorf("stackGOPATH() = %q, want the go directory in the home", got)
+	}
+}
@@ -0,0 +1,304 @@
+package olog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/welllog/olog/encoder"
+)
+
+// SyslogFormat is an enumeration type for the syslog message formats.
+type SyslogFormat uint8
+
+const (
+	// RFC5424 represents the syslog protocol format defined by RFC 5424.
+	RFC5424 SyslogFormat = iota
+	// RFC3164 represents the BSD syslog format defined by RFC 3164.
+	RFC3164
+)
+
+// SyslogFacility is the facility code of the syslog message.
+type SyslogFacility uint8
+
+const (
+	SyslogKern SyslogFacility = iota
+	SyslogUser
+	SyslogMail
+	SyslogDaemon
+	SyslogAuth
+	SyslogSyslog
+	SyslogLpr
+	SyslogNews
+	SyslogUucp
+	SyslogCron
+	SyslogAuthPriv
+	SyslogFtp
+	_
+	_
+	_
+	_
+	SyslogLocal0
+	SyslogLocal1
+	SyslogLocal2
+	SyslogLocal3
+	SyslogLocal4
+	SyslogLocal5
+	SyslogLocal6
+	SyslogLocal7
+)
+
+// syslogLocalPaths is the paths of the local syslog unix sockets.
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogWriter is a Writer that sends log messages to a syslog server.
+// The encoded log message is used as the MSG part of the syslog message. The Writer does not receive the App
+// of the logger, so the APP-NAME is set by WithSyslogApp.
+type SyslogWriter struct {
+	network  string         // network is the network of the syslog server, empty for local unix sockets
+	addr     string         // addr is the address of the syslog server
+	format   SyslogFormat   // format is the format of the syslog message
+	facility SyslogFacility // facility is the facility of the syslog message
+	app      string         // app is the APP-NAME of the syslog message
+	hostname string         // hostname is the HOSTNAME of the syslog message
+	pid      int            // pid is the PROCID of the syslog message
+
+	mu     sync.Mutex
+	conn   net.Conn
+	local  bool // local is whether the conn is a local unix socket
+	stream bool // stream is whether the conn is a stream connection
+}
+
+// SyslogOption is a functional option type for configuring a SyslogWriter.
+type SyslogOption func(*SyslogWriter)
+
+// WithSyslogAddr sets the network and address of the syslog server, such as ("udp", "localhost:514"),
+// ("tcp", "localhost:514") or ("unixgram", "/dev/log"). The local unix sockets are used by default.
+func WithSyslogAddr(network, addr string) SyslogOption {
+	return func(s *SyslogWriter) {
+		s.network = network
+		s.addr = addr
+	}
+}
+
+// WithSyslogFormat sets the format of the syslog message, RFC5424 is used by default.
+func WithSyslogFormat(f SyslogFormat) SyslogOption {
+	return func(s *SyslogWriter) {
+		s.format = f
+	}
+}
+
+// WithSyslogFacility sets the facility of the syslog message, SyslogUser is used by default.
+func WithSyslogFacility(f SyslogFacility) SyslogOption {
+	return func(s *SyslogWriter) {
+		s.facility = f
+	}
+}
+
+// WithSyslogApp sets the APP-NAME of the syslog message, it should be the App of the logger.
+// The base name of the program is used by default.
+func WithSyslogApp(name string) SyslogOption {
+	return func(s *SyslogWriter) {
+		s.app = name
+	}
+}
+
+// WithSyslogHostname sets the HOSTNAME of the syslog message, os.Hostname is used by default.
+func WithSyslogHostname(name string) SyslogOption {
+	return func(s *SyslogWriter) {
+		s.hostname = name
+	}
+}
+
+// NewSyslogWriter creates a new SyslogWriter and connects to the syslog server.
+// The connection is reestablished when sending a message fails.
+func NewSyslogWriter(opts ...SyslogOption) (*SyslogWriter, error) {
+	s := &SyslogWriter{
+		facility: SyslogUser,
+		app:      filepath.Base(os.Args[0]),
+		pid:      os.Getpid(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	s.app = syslogHeaderValue(s.app, 48)
+	s.hostname = syslogHeaderValue(s.hostname, 255)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write sends the byte slice p as a syslog message with the severity corresponding to the level.
+func (s *SyslogWriter) Write(level Level, p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		if err = s.send(level, p); err == nil {
+			return len(p), nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+
+	if err = s.connect(); err != nil {
+		return 0, err
+	}
+
+	if err = s.send(level, p); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog server.
+func (s *SyslogWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// connect connects to the syslog server, the caller must hold the lock.
+func (s *SyslogWriter) connect() error {
+	if s.network != "" {
+		conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.local = false
+		s.stream = !strings.HasPrefix(s.network, "udp") && s.network != "unixgram"
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range syslogLocalPaths {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				s.conn = conn
+				s.local = true
+				s.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("olog: unix syslog delivery error")
+}
+
+// send sends the byte slice p as a syslog message framed for the connection, the caller must hold the lock.
+func (s *SyslogWriter) send(level Level, p []byte) error {
+	buf := getBuf()
+	defer putBuf(buf)
+
+	s.writeMessage(buf, level, time.Now(), TrimLineEnding(p))
+	data := buf.Bytes()
+
+	if s.stream {
+		if s.local {
+			_ = buf.WriteByte('\n')
+			data = buf.Bytes()
+		} else {
+			// TCP uses octet counting framing.
+			frame := getBuf()
+			defer putBuf(frame)
+
+			frame.WriteInt64(int64(len(data)))
+			_ = frame.WriteByte(' ')
+			_, _ = frame.Write(data)
+			data = frame.Bytes()
+		}
+	}
+
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// writeMessage writes the syslog message to the buffer.
+func (s *SyslogWriter) writeMessage(buf *encoder.Buffer, level Level, t time.Time, msg []byte) {
+	_ = buf.WriteByte('<')
+	buf.WriteInt64(int64(s.facility)<<3 | int64(syslogSeverity(level)))
+	_ = buf.WriteByte('>')
+
+	if s.format == RFC3164 {
+		buf.WriteTime(t, time.Stamp)
+		_ = buf.WriteByte(' ')
+		if !s.local {
+			_, _ = buf.WriteString(s.hostname)
+			_ = buf.WriteByte(' ')
+		}
+		_, _ = buf.WriteString(s.app)
+		_ = buf.WriteByte('[')
+		buf.WriteInt64(int64(s.pid))
+		_, _ = buf.WriteString("]: ")
+	} else {
+		_, _ = buf.WriteString("1 ")
+		buf.WriteTime(t, "2006-01-02T15:04:05.000000Z07:00")
+		_ = buf.WriteByte(' ')
+		_, _ = buf.WriteString(s.hostname)
+		_ = buf.WriteByte(' ')
+		_, _ = buf.WriteString(s.app)
+		_ = buf.WriteByte(' ')
+		buf.WriteInt64(int64(s.pid))
+		_, _ = buf.WriteString(" - - ")
+	}
+
+	_, _ = buf.Write(msg)
+}
+
+// syslogSeverity returns the syslog severity corresponding to the level.
+func syslogSeverity(level Level) int {
+	switch severity := level.Severity(); {
+	case severity >= FATAL.Severity():
+		return 2 // LOG_CRIT
+	case severity >= ERROR.Severity():
+		return 3 // LOG_ERR
+	case severity >= WARN.Severity():
+		return 4 // LOG_WARNING
+	case severity >= NOTICE.Severity():
+		return 5 // LOG_NOTICE
+	case severity >= INFO.Severity():
+		return 6 // LOG_INFO
+	default:
+		return 7 // LOG_DEBUG
+	}
+}
+
+// syslogHeaderValue returns the value usable as a header field of the syslog message, which must be
+// printable US-ASCII without spaces and no longer than max, or "-" if it is empty.
+func syslogHeaderValue(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > max {
+		b = b[:max]
+	}
+	return string(b)
+}
@@ -0,0 +1,215 @@
+package olog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := NewSyslogWriter(
+		WithSyslogAddr("udp", pc.LocalAddr().String()),
+		WithSyslogApp("my app"),
+		WithSyslogHostname("host"),
+		WithSyslogFacility(SyslogLocal0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	logger := NewLogger(WithLoggerWriter(w), WithLoggerEncode(PLAIN), WithLoggerColor(false), WithLoggerCaller(false), WithLoggerTimeFormat(""))
+	logger.Noticew("hello", Field{Key: "name", Value: "bob"})
+
+	b := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re := regexp.MustCompile(`^<133>1 \S+ host my_app ` + strconv.Itoa(os.Getpid()) + ` - - \tnotice\thello\tname=bob$`)
+	if !re.Match(b[:n]) {
+		t.Errorf("unexpected message %q", b[:n])
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					size, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(size))
+					b := make([]byte, n)
+					if _, err := io.ReadFull(r, b); err != nil {
+						return
+					}
+					msgs <- string(b)
+					// drop the connection after the first message to force a reconnection
+					return
+				}
+			}(conn)
+		}
+	}()
+
+	w, err := NewSyslogWriter(
+		WithSyslogAddr("tcp", ln.Addr().String()),
+		WithSyslogFormat(RFC3164),
+		WithSyslogApp("app"),
+		WithSyslogHostname("host"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	re := regexp.MustCompile(`^<11>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} host app\[\d+\]: first$`)
+	if _, err := w.Write(ERROR, []byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgs:
+		if !re.MatchString(msg) {
+			t.Errorf("unexpected message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// the peer closed the connection, writes fail eventually and the writer reconnects.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _ = w.Write(WARN, []byte("second"))
+		select {
+		case msg := <-msgs:
+			if !strings.HasPrefix(msg, "<12>") || !strings.HasSuffix(msg, ": second") {
+				t.Errorf("unexpected message %q", msg)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestSyslogWriterUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	w, err := NewSyslogWriter(WithSyslogAddr("unixgram", path), WithSyslogApp("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write(DEBUG, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b[:n]), "<15>1 ") || !strings.HasSuffix(string(b[:n]), " app "+strconv.Itoa(os.Getpid())+" - - hello") {
+		t.Errorf("unexpected message %q", b[:n])
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	tests := []struct {
+		level    Level
+		severity int
+	}{
+		{level: TRACE, severity: 7},
+		{level: DEBUG, severity: 7},
+		{level: INFO, severity: 6},
+		{level: NOTICE, severity: 5},
+		{level: FATAL + 1, severity: 2},
+		{level: WARN, severity: 4},
+		{level: ERROR, severity: 3},
+		{level: FATAL, severity: 2},
+	}
+	for _, tt := range tests {
+		if s := syslogSeverity(tt.level); s != tt.severity {
+			t.Errorf("syslogSeverity(%s) = %d, want %d", tt.level, s, tt.severity)
+		}
+	}
+}
+
+func TestSyslogWriterApp(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	b := make([]byte, 1024)
+	for _, app := range []string{"svc api", ""} {
+		opts := []SyslogOption{WithSyslogAddr("udp", pc.LocalAddr().String()), WithSyslogHostname("host")}
+		if app != "" {
+			opts = append(opts, WithSyslogApp(app))
+		}
+		w, err := NewSyslogWriter(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logger := NewLogger(WithLoggerWriter(w), WithLoggerAppName(app), WithLoggerCaller(false))
+		logger.Info("hello")
+		_ = w.Close()
+
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the base name of the program is used without WithSyslogApp.
+		want := "svc_api"
+		if app == "" {
+			want = syslogHeaderValue(filepath.Base(os.Args[0]), 48)
+		}
+		re := regexp.MustCompile(`^<14>1 \S+ host ` + regexp.QuoteMeta(want) + ` \d+ - - \{"@timestamp":.*"content":"hello"\}$`)
+		if !re.Match(b[:n]) {
+			t.Errorf("unexpected message %q", b[:n])
+		}
+	}
+}
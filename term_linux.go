@@ -0,0 +1,26 @@
+//go:build linux
+
+package olog
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether the file is a terminal, which has the termios got by ioctl.
+func isTerminal(f *os.File) bool {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var (
+		termios syscall.Termios
+		errno   syscall.Errno
+	)
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	})
+	return err == nil && errno == 0
+}
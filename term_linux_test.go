@@ -0,0 +1,40 @@
+//go:build linux
+
+package olog
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if isTerminal(f) {
+		t.Error("a regular file is a terminal")
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	if isTerminal(devNull) {
+		t.Error("the null device is a terminal")
+	}
+
+	pty, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("open pseudo terminal: %v", err)
+	}
+	defer pty.Close()
+
+	if !isTerminal(pty) {
+		t.Error("a pseudo terminal is not a terminal")
+	}
+}
@@ -1,10 +1,15 @@
 package olog
 
 import (
+	"errors"
 	"io"
 	"os"
 )
 
+// ErrNotEncoded is returned by the writers, and passed to their failure callbacks, when a record is not
+// encoded by the Encode method of the writer, which must be set as the EncodeFunc of the logger.
+var ErrNotEncoded = errors.New("olog: record is not encoded by the Encode method of the writer")
+
 // csWriter is a variable that holds a new instance of consoleWriter created by calling the NewConsoleWriter function
 var csWriter = NewConsoleWriter()
 
@@ -17,13 +22,15 @@ type Writer interface {
 
 // consoleWriter is a struct that holds a standard output wr and a standard error wr
 type consoleWriter struct {
-	sw io.Writer
-	ew io.Writer
+	sw     io.Writer
+	ew     io.Writer
+	scolor bool // scolor reports whether the colors are used for the standard wr by default
+	ecolor bool // ecolor reports whether the colors are used for the error wr by default
 }
 
 // Write is a method on consoleWriter that writes the byte slice p to the standard wr or the error wr depending on the level parameter
 func (c *consoleWriter) Write(level Level, p []byte) (n int, err error) {
-	if level >= WARN {
+	if isStderrLevel(level) {
 		return c.ew.Write(p)
 	}
 	return c.sw.Write(p)
@@ -31,7 +38,8 @@ func (c *consoleWriter) Write(level Level, p []byte) (n int, err error) {
 
 // customWriter is a struct that holds a custom wr
 type customWriter struct {
-	w io.Writer
+	w     io.Writer
+	color bool // color reports whether the colors are used for the wr by default
 }
 
 // Write is a method on customWriter that writes the byte slice p to the custom wr
@@ -42,8 +50,10 @@ func (c *customWriter) Write(level Level, p []byte) (n int, err error) {
 // NewConsoleWriter is a function that creates a new consoleWriter with os.Stdout as the standard wr and os.Stderr as the error wr
 func NewConsoleWriter() Writer {
 	return &consoleWriter{
-		sw: os.Stdout,
-		ew: os.Stderr,
+		sw:     os.Stdout,
+		ew:     os.Stderr,
+		scolor: autoColor(os.Stdout),
+		ecolor: autoColor(os.Stderr),
 	}
 }
 
@@ -51,7 +61,11 @@ func NewConsoleWriter() Writer {
 // Special attention must be paid to the fact that []byte should not exceed the scope of the Write method.
 // After the Write method ends, the byte slice should not be used, otherwise will cause memory data errors.
 func NewWriter(w io.Writer) Writer {
-	return &customWriter{
+	c := &customWriter{
 		w: w,
 	}
+	if f, ok := w.(*os.File); ok {
+		c.color = autoColor(f)
+	}
+	return c
 }
@@ -0,0 +1,341 @@
+// Package batch batches the records written to the writers of olog, such as the fluent, OTLP, Loki and HTTP
+// writers, and sends the batches in background with retries.
+package batch
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is passed to the failure callback when a batch is dropped because the queue is full.
+var ErrQueueFull = errors.New("olog: batch queue is full")
+
+// ErrClosed is passed to the failure callback when records are added after the writer is closed.
+var ErrClosed = errors.New("olog: batch writer is closed")
+
+// options configures the batching, sending and retrying of a Writer.
+type options struct {
+	maxCount   int                               // maxCount is the maximum number of records in a batch
+	maxBytes   int                               // maxBytes is the maximum number of bytes in a batch
+	maxAge     time.Duration                     // maxAge is the maximum time a record waits before its batch is sent
+	queueSize  int                               // queueSize is the maximum number of batches waiting to be sent
+	workers    int                               // workers is the number of batches sent concurrently
+	maxRetries int                               // maxRetries is the maximum number of retries of sending a batch
+	minBackoff time.Duration                     // minBackoff is the delay before the first retry
+	maxBackoff time.Duration                     // maxBackoff is the maximum delay between retries
+	onFailure  func(records [][]byte, err error) // onFailure is called with the batch failed permanently
+}
+
+// Option is a functional option type for configuring the batching of the writers.
+type Option func(*options)
+
+// WithSize sets the maximum number of records and bytes of a batch, the batch is sent when either is reached.
+func WithSize(count, bytes int) Option {
+	return func(o *options) {
+		o.maxCount = count
+		o.maxBytes = bytes
+	}
+}
+
+// WithAge sets the maximum time a record waits before its batch is sent.
+func WithAge(age time.Duration) Option {
+	return func(o *options) {
+		o.maxAge = age
+	}
+}
+
+// WithQueue sets the maximum number of batches waiting to be sent, batches are dropped when the queue is full.
+func WithQueue(size int) Option {
+	return func(o *options) {
+		o.queueSize = size
+	}
+}
+
+// WithWorkers sets the number of batches sent concurrently, the order of the records is only kept with one worker.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithRetry sets the maximum number of retries of sending a batch, and the delay between retries,
+// which doubles after each retry with jitter.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		o.maxRetries = maxRetries
+		o.minBackoff = minBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// WithFailure sets the callback called with the records of a batch that failed permanently,
+// which is not retryable, dropped, or still failing after all the retries.
+func WithFailure(fn func(records [][]byte, err error)) Option {
+	return func(o *options) {
+		o.onFailure = fn
+	}
+}
+
+// Permanent returns the error which stops retrying the batch, it is returned by the send function when the
+// batch cannot be sent by retrying, such as rejected by the server.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// permanentError is an error that sending a batch should not be retried.
+type permanentError struct {
+	err error
+}
+
+func (p permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p permanentError) Unwrap() error {
+	return p.err
+}
+
+// Partial returns the error of sending a batch of which only the remaining records are not sent, such as the
+// records of the chunks not acknowledged, the retries and the failure callback only get the remaining records
+// so that the records sent are not sent again. It can be wrapped by Permanent.
+func Partial(remaining [][]byte, err error) error {
+	return partialError{remaining: remaining, err: err}
+}
+
+// partialError is an error that only the remaining records of a batch are not sent.
+type partialError struct {
+	remaining [][]byte
+	err       error
+}
+
+func (p partialError) Error() string {
+	return p.err.Error()
+}
+
+func (p partialError) Unwrap() error {
+	return p.err
+}
+
+// Writer copies the added records into batches, and sends the batches in background.
+type Writer struct {
+	opts options
+	send func(records [][]byte) error
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	records [][]byte
+	size    int
+	timer   *time.Timer
+	seq     uint64 // seq is increased when the current batch is replaced, to stop a stale timer
+	pending int    // pending is the number of batches queued or being sent
+	closed  bool
+
+	queue chan [][]byte
+	wg    sync.WaitGroup
+}
+
+// New creates a new Writer sending the batches by the send function, the send function can return an error
+// made by Permanent to stop retrying.
+func New(send func(records [][]byte) error, opts ...Option) *Writer {
+	b := &Writer{
+		opts: options{
+			maxCount:   1000,
+			maxBytes:   1 << 20,
+			maxAge:     time.Second,
+			queueSize:  64,
+			workers:    1,
+			maxRetries: 5,
+			minBackoff: 100 * time.Millisecond,
+			maxBackoff: 10 * time.Second,
+		},
+		send: send,
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	if b.opts.workers < 1 {
+		b.opts.workers = 1
+	}
+	if b.opts.queueSize < 1 {
+		b.opts.queueSize = 1
+	}
+	if b.opts.minBackoff <= 0 {
+		b.opts.minBackoff = time.Millisecond
+	}
+
+	b.cond = sync.NewCond(&b.mu)
+	b.queue = make(chan [][]byte, b.opts.queueSize)
+	for i := 0; i < b.opts.workers; i++ {
+		b.wg.Add(1)
+		go b.work()
+	}
+	return b
+}
+
+// Add copies the record into the current batch, the record can be used by the caller after it returns.
+func (b *Writer) Add(p []byte) {
+	record := make([]byte, len(p))
+	copy(record, p)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		b.fail([][]byte{record}, ErrClosed)
+		return
+	}
+
+	var dropped [][]byte
+	if len(b.records) > 0 && b.size+len(record) > b.opts.maxBytes {
+		dropped = b.flushLocked()
+	}
+
+	b.records = append(b.records, record)
+	b.size += len(record)
+
+	if len(b.records) >= b.opts.maxCount || b.size >= b.opts.maxBytes {
+		if d := b.flushLocked(); d != nil {
+			dropped = append(dropped, d...)
+		}
+	} else if len(b.records) == 1 && b.opts.maxAge > 0 {
+		seq := b.seq
+		b.timer = time.AfterFunc(b.opts.maxAge, func() {
+			b.mu.Lock()
+			var dropped [][]byte
+			if b.seq == seq {
+				dropped = b.flushLocked()
+			}
+			b.mu.Unlock()
+
+			if dropped != nil {
+				b.fail(dropped, ErrQueueFull)
+			}
+		})
+	}
+	b.mu.Unlock()
+
+	if dropped != nil {
+		b.fail(dropped, ErrQueueFull)
+	}
+}
+
+// flushLocked queues the current batch, and returns the records of the batch if it is dropped because
+// the queue is full. The caller must hold the lock.
+func (b *Writer) flushLocked() [][]byte {
+	b.seq++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.records) == 0 {
+		return nil
+	}
+
+	records := b.records
+	b.records = nil
+	b.size = 0
+
+	select {
+	case b.queue <- records:
+		b.pending++
+		return nil
+	default:
+		return records
+	}
+}
+
+// Flush sends the current batch, and waits until all the queued batches are sent.
+func (b *Writer) Flush() {
+	b.mu.Lock()
+	dropped := b.flushLocked()
+	for b.pending > 0 {
+		b.cond.Wait()
+	}
+	b.mu.Unlock()
+
+	if dropped != nil {
+		b.fail(dropped, ErrQueueFull)
+	}
+}
+
+// Close sends the current batch, waits until all the queued batches are sent, and stops the workers.
+func (b *Writer) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	dropped := b.flushLocked()
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	if dropped != nil {
+		b.fail(dropped, ErrQueueFull)
+	}
+	b.wg.Wait()
+	return nil
+}
+
+// work sends the queued batches.
+func (b *Writer) work() {
+	defer b.wg.Done()
+
+	for records := range b.queue {
+		if remaining, err := b.sendWithRetry(records); err != nil {
+			b.fail(remaining, err)
+		}
+
+		b.mu.Lock()
+		b.pending--
+		if b.pending == 0 {
+			b.cond.Broadcast()
+		}
+		b.mu.Unlock()
+	}
+}
+
+// sendWithRetry sends the batch, and retries with exponential backoff and jitter if it fails. It returns the
+// records not sent if it fails.
+func (b *Writer) sendWithRetry(records [][]byte) ([][]byte, error) {
+	backoff := b.opts.minBackoff
+	for i := 0; ; i++ {
+		err := b.send(records)
+		if err == nil {
+			return nil, nil
+		}
+
+		var partial partialError
+		if errors.As(err, &partial) {
+			records = partial.remaining
+		}
+		var pe permanentError
+		if errors.As(err, &pe) || i >= b.opts.maxRetries {
+			return records, err
+		}
+
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+		if b.opts.maxBackoff > 0 && backoff > b.opts.maxBackoff {
+			backoff = b.opts.maxBackoff
+		}
+	}
+}
+
+// Reject calls the failure callback with a copy of the record which cannot be sent, and returns the error.
+func (b *Writer) Reject(p []byte, err error) error {
+	if b.opts.onFailure != nil {
+		record := make([]byte, len(p))
+		copy(record, p)
+		b.opts.onFailure([][]byte{record}, err)
+	}
+	return err
+}
+
+// fail calls the failure callback with the records.
+func (b *Writer) fail(records [][]byte, err error) {
+	if b.opts.onFailure != nil {
+		b.opts.onFailure(records, err)
+	}
+}
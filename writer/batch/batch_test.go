@@ -0,0 +1,212 @@
+package batch
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder records the batches sent by a Writer.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	fails   int
+}
+
+func (b *batchRecorder) send(records [][]byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fails > 0 {
+		b.fails--
+		return errors.New("temporary failure")
+	}
+
+	batch := make([]string, len(records))
+	for i, record := range records {
+		batch[i] = string(record)
+	}
+	b.batches = append(b.batches, batch)
+	return nil
+}
+
+func (b *batchRecorder) get() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batches
+}
+
+func TestWriterSize(t *testing.T) {
+	rec := &batchRecorder{}
+	b := New(rec.send, WithSize(3, 10), WithAge(time.Hour))
+
+	p := []byte("0")
+	for i := 0; i < 4; i++ {
+		b.Add(p)
+		// the record is copied.
+		p[0]++
+	}
+	b.Add([]byte("123456789"))
+	b.Add([]byte("x"))
+	_ = b.Close()
+
+	want := "[[0 1 2] [3 123456789] [x]]"
+	if got := fmtBatches(rec.get()); got != want {
+		t.Errorf("batches = %s, want %s", got, want)
+	}
+}
+
+func TestWriterAge(t *testing.T) {
+	rec := &batchRecorder{}
+	b := New(rec.send, WithAge(10*time.Millisecond))
+	defer b.Close()
+
+	b.Add([]byte("a"))
+	b.Add([]byte("b"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.get()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for batch")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := fmtBatches(rec.get()); got != "[[a b]]" {
+		t.Errorf("batches = %s, want [[a b]]", got)
+	}
+}
+
+func TestWriterRetry(t *testing.T) {
+	rec := &batchRecorder{fails: 2}
+	var failed [][]byte
+	b := New(rec.send,
+		WithRetry(2, time.Millisecond, time.Millisecond),
+		WithFailure(func(records [][]byte, err error) {
+			failed = append(failed, records...)
+		}),
+	)
+
+	b.Add([]byte("a"))
+	b.Flush()
+	if got := fmtBatches(rec.get()); got != "[[a]]" {
+		t.Errorf("batches = %s, want [[a]]", got)
+	}
+
+	rec.fails = 3
+	b.Add([]byte("b"))
+	b.Flush()
+	if len(failed) != 1 || string(failed[0]) != "b" {
+		t.Errorf("failed = %q, want [b]", failed)
+	}
+
+	calls := 0
+	b2 := New(func(records [][]byte) error {
+		calls++
+		return Permanent(errors.New("bad request"))
+	}, WithRetry(5, time.Millisecond, time.Millisecond))
+	b2.Add([]byte("c"))
+	_ = b2.Close()
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	_ = b.Close()
+	b.Add([]byte("d"))
+	if len(failed) != 2 || string(failed[1]) != "d" {
+		t.Errorf("failed = %q, want [b d]", failed)
+	}
+}
+
+func TestWriterPartial(t *testing.T) {
+	var (
+		sent   [][]string
+		failed []string
+	)
+	b := New(func(records [][]byte) error {
+		var batch []string
+		for _, record := range records {
+			batch = append(batch, string(record))
+		}
+		sent = append(sent, batch)
+		if len(sent) == 3 {
+			return Permanent(Partial(records[1:], errors.New("rejected")))
+		}
+		return Partial(records[1:], errors.New("unavailable"))
+	},
+		WithSize(4, 1<<20),
+		WithRetry(5, time.Millisecond, time.Millisecond),
+		WithFailure(func(records [][]byte, err error) {
+			for _, record := range records {
+				failed = append(failed, string(record))
+			}
+		}),
+	)
+	for _, record := range []string{"a", "b", "c", "d"} {
+		b.Add([]byte(record))
+	}
+	_ = b.Close()
+
+	// the retries only send the records not sent.
+	want := [][]string{{"a", "b", "c", "d"}, {"b", "c", "d"}, {"c", "d"}}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent = %q, want %q", sent, want)
+	}
+	if !reflect.DeepEqual(failed, []string{"d"}) {
+		t.Errorf("failed = %q, want [d]", failed)
+	}
+}
+
+func TestWriterQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var dropped []string
+	b := New(func(records [][]byte) error {
+		<-block
+		return nil
+	},
+		WithSize(1, 1<<20),
+		WithQueue(1),
+		WithFailure(func(records [][]byte, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, ErrQueueFull) {
+				dropped = append(dropped, string(records[0]))
+			}
+		}),
+	)
+
+	for i := 0; i < 5; i++ {
+		b.Add([]byte(strconv.Itoa(i)))
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(block)
+	_ = b.Close()
+
+	// the first batch is being sent, the second one is queued, the others are dropped.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dropped) != 3 {
+		t.Errorf("dropped = %v, want 3 batches", dropped)
+	}
+}
+
+func fmtBatches(batches [][]string) string {
+	s := "["
+	for i, batch := range batches {
+		if i > 0 {
+			s += " "
+		}
+		s += "["
+		for j, record := range batch {
+			if j > 0 {
+				s += " "
+			}
+			s += record
+		}
+		s += "]"
+	}
+	return s + "]"
+}
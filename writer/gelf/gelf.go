@@ -0,0 +1,197 @@
+// Package gelf provides the Writer sending the log records of olog encoded by olog.GelfEncode to Graylog.
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/welllog/olog"
+)
+
+// errClosed is returned by Write after the writer is closed.
+var errClosed = errors.New("olog: gelf writer is closed")
+
+// maxChunks is the maximum number of chunks of a GELF message.
+const maxChunks = 128
+
+// Writer is an olog.Writer that sends GELF messages encoded by olog.GelfEncode to Graylog, as chunked and optionally
+// gzip compressed UDP datagrams, or null byte terminated TCP frames.
+type Writer struct {
+	network   string
+	addr      string
+	compress  bool
+	chunkSize int
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	udp    bool
+	msgID  uint64
+}
+
+// Option is a functional option type for configuring a Writer.
+type Option func(*Writer)
+
+// WithCompress sets whether to compress the UDP datagrams with gzip.
+func WithCompress(enable bool) Option {
+	return func(g *Writer) {
+		g.compress = enable
+	}
+}
+
+// WithChunkSize sets the maximum size of the UDP datagrams, messages larger than it are chunked.
+// 1420 is used by default, which fits the common MTU.
+func WithChunkSize(size int) Option {
+	return func(g *Writer) {
+		g.chunkSize = size
+	}
+}
+
+// New creates a new Writer and connects to the GELF input at the network address,
+// the network is "udp" or "tcp". The TCP connection is reestablished when sending a message fails.
+func New(network, addr string, opts ...Option) (*Writer, error) {
+	g := &Writer{
+		network:   network,
+		addr:      addr,
+		chunkSize: 1420,
+		udp:       strings.HasPrefix(network, "udp"),
+		msgID:     rand.Uint64(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.chunkSize < 13 {
+		return nil, errors.New("olog: gelf chunk size is too small")
+	}
+
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	g.conn = conn
+	return g, nil
+}
+
+// gzipPool pools the gzip writers, which are expensive to create.
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// Write sends the GELF message.
+func (g *Writer) Write(level olog.Level, p []byte) (n int, err error) {
+	msg := olog.TrimLineEnding(p)
+
+	if g.udp {
+		if g.compress {
+			var buf bytes.Buffer
+			zw := gzipPool.Get().(*gzip.Writer)
+			zw.Reset(&buf)
+			_, _ = zw.Write(msg)
+			_ = zw.Close()
+			gzipPool.Put(zw)
+			msg = buf.Bytes()
+		}
+
+		if err = g.writeUDP(msg); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return 0, errClosed
+	}
+	if g.conn == nil {
+		if g.conn, err = net.DialTimeout(g.network, g.addr, 5*time.Second); err != nil {
+			return 0, err
+		}
+	}
+
+	frame := append(append(make([]byte, 0, len(msg)+1), msg...), 0)
+	if _, err = g.conn.Write(frame); err != nil {
+		// reconnect and retry once.
+		_ = g.conn.Close()
+		if g.conn, err = net.DialTimeout(g.network, g.addr, 5*time.Second); err != nil {
+			return 0, err
+		}
+		if _, err = g.conn.Write(frame); err != nil {
+			_ = g.conn.Close()
+			g.conn = nil
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// writeUDP sends the message as a datagram, or as chunks if it is larger than the chunk size.
+func (g *Writer) writeUDP(msg []byte) error {
+	// the connection is closed by Close concurrently, and the datagrams are written without the lock.
+	g.mu.Lock()
+	conn, closed := g.conn, g.closed
+	g.mu.Unlock()
+	if closed {
+		return errClosed
+	}
+
+	if len(msg) <= g.chunkSize {
+		_, err := conn.Write(msg)
+		return err
+	}
+
+	// each chunk has a 12 bytes header: magic bytes, message id, sequence number and sequence count.
+	size := g.chunkSize - 12
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return errors.New("olog: gelf message is too large")
+	}
+
+	chunk := make([]byte, 0, g.chunkSize)
+	var header [12]byte
+	header[0], header[1] = 0x1e, 0x0f
+	binary.BigEndian.PutUint64(header[2:10], atomic.AddUint64(&g.msgID, 1))
+	header[11] = byte(count)
+
+	for i := 0; i < count; i++ {
+		header[10] = byte(i)
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk = append(append(chunk[:0], header[:]...), msg[i*size:end]...)
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (g *Writer) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
@@ -0,0 +1,180 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog"
+)
+
+func TestWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := New("udp", pc.LocalAddr().String(), WithCompress(true), WithChunkSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(olog.GelfEncode))
+	msg := strings.Repeat("0123456789", 100)
+	logger.Info(msg)
+
+	// reassemble the chunks.
+	var (
+		chunks [][]byte
+		count  = -1
+	)
+	b := make([]byte, 1024)
+	for count < 0 || len(chunks) < count {
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 64 {
+			t.Fatalf("datagram size = %d, want <= 64", n)
+		}
+		if b[0] != 0x1e || b[1] != 0x0f {
+			t.Fatalf("invalid chunk magic % x", b[:2])
+		}
+		if count < 0 {
+			count = int(b[11])
+			chunks = make([][]byte, 0, count)
+		}
+		if int(b[10]) != len(chunks) {
+			t.Fatalf("chunk sequence = %d, want %d", b[10], len(chunks))
+		}
+		chunks = append(chunks, append([]byte(nil), b[12:n]...))
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(bytes.Join(chunks, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if m["short_message"] != msg {
+		t.Errorf("short_message = %v, want %s", m["short_message"], msg)
+	}
+}
+
+func TestWriterUDPClose(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := New("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = w.Write(olog.INFO, []byte(`{"short_message":"hello"}`))
+			}
+		}()
+	}
+	_ = w.Close()
+	wg.Wait()
+
+	if _, err := w.Write(olog.INFO, []byte(`{"short_message":"hello"}`)); err != errClosed {
+		t.Errorf("Write error = %v, want %v", err, errClosed)
+	}
+}
+
+func TestWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	w, err := New("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(olog.GelfEncode))
+	logger.Warn("first")
+	logger.Warn("second")
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case msg := <-msgs:
+			m := make(map[string]any)
+			if err := json.Unmarshal([]byte(strings.TrimSuffix(msg, "\x00")), &m); err != nil {
+				t.Fatalf("unmarshal %q: %v", msg, err)
+			}
+			if m["short_message"] != want || m["level"] != float64(4) {
+				t.Errorf("message = %v", m)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestWriterTCPClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := New("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the writer does not redial after it is closed.
+	if _, err := w.Write(olog.INFO, []byte(`{"short_message":"hello"}`)); err != errClosed {
+		t.Errorf("Write error = %v, want %v", err, errClosed)
+	}
+}
@@ -0,0 +1,245 @@
+// Package httpbatch provides the Writer sending the log records of olog in batches to an HTTP endpoint, such
+// as the Datadog logs intake, the Splunk HTTP Event Collector or the Elasticsearch _bulk API.
+package httpbatch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// Framing is an enumeration type for the body framings of the batches sent by Writer.
+type Framing int8
+
+const (
+	// NDJSON frames the records as newline delimited JSON.
+	NDJSON Framing = iota
+	// JSONArray frames the records as a JSON array, the records must be JSON objects.
+	JSONArray
+	// Bulk frames the records as the Elasticsearch _bulk API body, each record follows an action line. The
+	// records of the actions failed in the response are passed to the failure callback.
+	Bulk
+)
+
+// Writer is an olog.Writer that sends log records in batches to an HTTP endpoint. The records are copied into
+// batches, framed and optionally gzip compressed, and sent by the workers in background. The requests are
+// retried with backoff and jitter on network errors, 408, 429 and 5xx responses, and the records of the
+// batches failed permanently are passed to the failure callback set by batch.WithFailure.
+type Writer struct {
+	url        string
+	client     *http.Client
+	headers    http.Header
+	framing    Framing
+	bulkAction []byte
+	gzip       bool
+	batchOpts  []batch.Option
+
+	batch *batch.Writer
+}
+
+// Option is a functional option type for configuring a Writer.
+type Option func(*Writer)
+
+// WithHeader adds a header to the requests, such as the api key of the endpoint.
+func WithHeader(key, value string) Option {
+	return func(h *Writer) {
+		h.headers.Add(key, value)
+	}
+}
+
+// WithClient sets the http client of the requests, a client with 10 seconds timeout is used by default.
+func WithClient(client *http.Client) Option {
+	return func(h *Writer) {
+		h.client = client
+	}
+}
+
+// WithFraming sets the body framing of the batches, NDJSON is used by default.
+func WithFraming(framing Framing) Option {
+	return func(h *Writer) {
+		h.framing = framing
+	}
+}
+
+// WithBulkAction sets the action line preceding each record with the Bulk framing,
+// such as `{"create":{"_index":"logs-app"}}`. `{"index":{}}` is used by default.
+func WithBulkAction(action string) Option {
+	return func(h *Writer) {
+		h.bulkAction = []byte(action)
+	}
+}
+
+// WithGzip sets whether to compress the request bodies with gzip.
+func WithGzip(enable bool) Option {
+	return func(h *Writer) {
+		h.gzip = enable
+	}
+}
+
+// WithBatch sets the batching options, the number of concurrent requests is set by batch.WithWorkers.
+func WithBatch(opts ...batch.Option) Option {
+	return func(h *Writer) {
+		h.batchOpts = append(h.batchOpts, opts...)
+	}
+}
+
+// New creates a new Writer posting the batches to the url.
+func New(url string, opts ...Option) *Writer {
+	h := &Writer{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		headers:    make(http.Header),
+		bulkAction: []byte(`{"index":{}}`),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.batch = batch.New(h.send, h.batchOpts...)
+	return h
+}
+
+// Write copies the record without the line ending into the current batch.
+func (h *Writer) Write(level olog.Level, p []byte) (n int, err error) {
+	h.batch.Add(olog.TrimLineEnding(p))
+	return len(p), nil
+}
+
+// Flush sends the current batch and waits until all the batches are sent.
+func (h *Writer) Flush() {
+	h.batch.Flush()
+}
+
+// Close sends the remaining batches.
+func (h *Writer) Close() error {
+	return h.batch.Close()
+}
+
+// gzipPool pools the gzip writers, which are expensive to create.
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// send posts the batch framed and compressed.
+func (h *Writer) send(records [][]byte) error {
+	var buf bytes.Buffer
+
+	contentType := "application/x-ndjson"
+	switch h.framing {
+	case JSONArray:
+		contentType = "application/json"
+		_ = buf.WriteByte('[')
+		for i, record := range records {
+			if i > 0 {
+				_ = buf.WriteByte(',')
+			}
+			_, _ = buf.Write(record)
+		}
+		_ = buf.WriteByte(']')
+	case Bulk:
+		for _, record := range records {
+			_, _ = buf.Write(h.bulkAction)
+			_ = buf.WriteByte('\n')
+			_, _ = buf.Write(record)
+			_ = buf.WriteByte('\n')
+		}
+	default:
+		for _, record := range records {
+			_, _ = buf.Write(record)
+			_ = buf.WriteByte('\n')
+		}
+	}
+
+	body := buf.Bytes()
+	if h.gzip {
+		var zbuf bytes.Buffer
+		zw := gzipPool.Get().(*gzip.Writer)
+		zw.Reset(&zbuf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		gzipPool.Put(zw)
+		body = zbuf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	for key, values := range h.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if h.framing == Bulk {
+			h.rejectBulkItems(records, resp.Body)
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return fmt.Errorf("olog: http batch failed with status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	default:
+		return batch.Permanent(fmt.Errorf("olog: http batch failed with status %s: %s", resp.Status, bytes.TrimSpace(msg)))
+	}
+}
+
+// bulkResponse is the response of the Elasticsearch _bulk API, the items are in the order of the actions.
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkItemResponse `json:"items"`
+}
+
+// bulkItemResponse is the result of an action of the _bulk API.
+type bulkItemResponse struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// rejectBulkItems passes the records whose actions failed to the failure callback. The _bulk API responds
+// with 200 even if some actions failed, with the errors flag set and the status of each action in the items.
+// The failed records are not retried, since resending the batch would duplicate the indexed records.
+func (h *Writer) rejectBulkItems(records [][]byte, body io.Reader) {
+	var resp bulkResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil || !resp.Errors {
+		return
+	}
+
+	for i, item := range resp.Items {
+		if i >= len(records) {
+			break
+		}
+		for action, result := range item {
+			if result.Status >= 200 && result.Status < 300 {
+				continue
+			}
+			_ = h.batch.Reject(records[i], fmt.Errorf("olog: http bulk %s failed with status %d: %s",
+				action, result.Status, bytes.TrimSpace(result.Error)))
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package httpbatch
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/internal/testserver"
+	"github.com/welllog/olog/writer/batch"
+)
+
+func TestWriterFraming(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		body     string
+		mimeType string
+	}{
+		{
+			name:     "ndjson",
+			body:     `{"n":1}` + "\n" + `{"n":2}` + "\n",
+			mimeType: "application/x-ndjson",
+		},
+		{
+			name:     "json array gzip",
+			opts:     []Option{WithFraming(JSONArray), WithGzip(true)},
+			body:     `[{"n":1},{"n":2}]`,
+			mimeType: "application/json",
+		},
+		{
+			name:     "bulk",
+			opts:     []Option{WithFraming(Bulk), WithBulkAction(`{"create":{"_index":"logs"}}`)},
+			body:     `{"create":{"_index":"logs"}}` + "\n" + `{"n":1}` + "\n" + `{"create":{"_index":"logs"}}` + "\n" + `{"n":2}` + "\n",
+			mimeType: "application/x-ndjson",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testserver.New(t, http.StatusOK, "rejected by sink\n")
+			w := New(s.URL, tt.opts...)
+
+			_, _ = w.Write(olog.INFO, []byte(`{"n":1}`+"\n"))
+			_, _ = w.Write(olog.INFO, []byte(`{"n":2}`))
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := s.Requests()
+			if len(reqs) != 1 {
+				t.Fatalf("requests = %d, want 1", len(reqs))
+			}
+			if body := string(reqs[0].Body); body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+			if mimeType := reqs[0].Header.Get("Content-Type"); mimeType != tt.mimeType {
+				t.Errorf("Content-Type = %q, want %q", mimeType, tt.mimeType)
+			}
+		})
+	}
+}
+
+func TestWriterConcurrency(t *testing.T) {
+	s := testserver.New(t, http.StatusOK, "rejected by sink\n")
+	w := New(s.URL, WithHeader("DD-API-KEY", "key"),
+		WithBatch(batch.WithSize(10, 1<<20), batch.WithQueue(100), batch.WithWorkers(4)))
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerCaller(false))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Infow("hello", olog.Field{Key: "j", Value: j})
+			}
+		}()
+	}
+	wg.Wait()
+	_ = w.Close()
+
+	var n int
+	for _, req := range s.Requests() {
+		body := string(req.Body)
+		n += strings.Count(body, "\n")
+		if !strings.HasPrefix(body, `{"@timestamp":`) {
+			t.Errorf("body = %q", body)
+		}
+	}
+	if n != 1000 {
+		t.Errorf("records = %d, want 1000", n)
+	}
+}
+
+func TestWriterFailure(t *testing.T) {
+	s := testserver.New(t, http.StatusOK, "rejected by sink\n", http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK,
+		http.StatusUnauthorized)
+
+	var (
+		mu      sync.Mutex
+		failed  [][]byte
+		failErr error
+	)
+	w := New(s.URL, WithBatch(
+		batch.WithRetry(3, time.Millisecond, time.Millisecond),
+		batch.WithFailure(func(records [][]byte, err error) {
+			mu.Lock()
+			failed = append(failed, records...)
+			failErr = err
+			mu.Unlock()
+		}),
+	))
+	defer w.Close()
+
+	_, _ = w.Write(olog.INFO, []byte("retried\n"))
+	w.Flush()
+
+	if n := len(s.Requests()); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+
+	_, _ = w.Write(olog.INFO, []byte("rejected\n"))
+	w.Flush()
+
+	if n := len(s.Requests()); n != 4 {
+		t.Errorf("requests = %d, want 4", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || string(failed[0]) != "rejected" {
+		t.Errorf("failed records = %q", failed)
+	}
+	if failErr == nil || !strings.Contains(failErr.Error(), "401 Unauthorized: rejected by sink") {
+		t.Errorf("failure error = %v", failErr)
+	}
+}
+
+func TestWriterBulkErrors(t *testing.T) {
+	s := testserver.New(t, http.StatusOK, `{"took":3,"errors":true,"items":[`+
+		`{"create":{"_index":"logs","status":201}},`+
+		`{"create":{"_index":"logs","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}},`+
+		`{"create":{"_index":"logs","status":201}}]}`)
+
+	var (
+		mu      sync.Mutex
+		failed  [][]byte
+		failErr error
+	)
+	w := New(s.URL, WithFraming(Bulk), WithBatch(
+		batch.WithRetry(3, time.Millisecond, time.Millisecond),
+		batch.WithFailure(func(records [][]byte, err error) {
+			mu.Lock()
+			failed = append(failed, records...)
+			failErr = err
+			mu.Unlock()
+		}),
+	))
+	defer w.Close()
+
+	for _, record := range []string{`{"msg":"a"}`, `{"msg":"b"}`, `{"msg":"c"}`} {
+		_, _ = w.Write(olog.INFO, []byte(record+"\n"))
+	}
+	w.Flush()
+
+	if n := len(s.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || string(failed[0]) != `{"msg":"b"}` {
+		t.Errorf("failed records = %q", failed)
+	}
+	if failErr == nil || !strings.Contains(failErr.Error(), "create failed with status 400") ||
+		!strings.Contains(failErr.Error(), "mapper_parsing_exception") {
+		t.Errorf("failure error = %v", failErr)
+	}
+}
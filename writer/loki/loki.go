@@ -0,0 +1,417 @@
+// Package loki provides the Writer pushing the log records of olog to Grafana Loki with the push API.
+package loki
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/encoder"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// keys of the record which can be turned into labels besides the fields.
+const (
+	keyApp    = "app"
+	keyLevel  = "level"
+	keyLogger = "logger"
+)
+
+// streamLabel is a stream label of the Writer, whose value is static or taken from the record.
+type streamLabel struct {
+	name   string // name is the valid label name
+	key    string // key is the field key of the value, or empty for a static label
+	value  string // value is the escaped static value
+	static bool
+}
+
+// Writer is an olog.Writer that pushes log records to Grafana Loki with the push API in JSON.
+// The App, the level tag and the chosen low-cardinality fields are turned into stream labels, and the rest
+// of the record is kept in the line as a JSON object. Records are batched and sent by one worker, the pushes
+// are retried on 429 and 5xx responses, and the timestamps of a stream are kept increasing so that the
+// entries are not rejected as out of order.
+// It must be used together with its Encode method as the EncodeFunc of the logger:
+//
+//	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode))
+type Writer struct {
+	url       string
+	client    *http.Client
+	headers   http.Header
+	labels    []streamLabel
+	labelKeys map[string]struct{}
+	batchOpts []batch.Option
+
+	lastTs map[string]int64 // lastTs is the last timestamp of each stream, only used by the single batch worker
+	batch  *batch.Writer
+}
+
+// Option is a functional option type for configuring a Writer.
+type Option func(*Writer)
+
+// WithLabelKeys sets the keys of the fields turned into stream labels in addition to the app and level,
+// the keys should have low cardinality values, the key "logger" is the name of the logger created by Named.
+// The characters not allowed in label names are replaced by underscores.
+func WithLabelKeys(keys ...string) Option {
+	return func(l *Writer) {
+		for _, key := range keys {
+			l.labels = append(l.labels, streamLabel{name: labelName(key), key: key})
+		}
+	}
+}
+
+// WithStaticLabels adds the labels to all the streams, such as the env or the host.
+func WithStaticLabels(labels map[string]string) Option {
+	return func(l *Writer) {
+		for name, value := range labels {
+			l.labels = append(l.labels, streamLabel{name: labelName(name), value: olog.EscapedString(value), static: true})
+		}
+	}
+}
+
+// WithTenant sets the tenant id sent as the X-Scope-OrgID header for multi-tenant Loki.
+func WithTenant(tenant string) Option {
+	return func(l *Writer) {
+		l.headers.Set("X-Scope-OrgID", tenant)
+	}
+}
+
+// WithHeader adds a header to the push requests, such as the authorization of the gateway.
+func WithHeader(key, value string) Option {
+	return func(l *Writer) {
+		l.headers.Add(key, value)
+	}
+}
+
+// WithClient sets the http client of the push requests, a client with 10 seconds timeout is used by default.
+func WithClient(client *http.Client) Option {
+	return func(l *Writer) {
+		l.client = client
+	}
+}
+
+// WithBatch sets the batching options, the batches are always sent by one worker.
+func WithBatch(opts ...batch.Option) Option {
+	return func(l *Writer) {
+		l.batchOpts = append(l.batchOpts, opts...)
+	}
+}
+
+// New creates a new Writer pushing records to the Loki push API url,
+// such as "http://localhost:3100/loki/api/v1/push".
+func New(url string, opts ...Option) *Writer {
+	l := &Writer{
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		headers: make(http.Header),
+		labels: []streamLabel{
+			{name: keyApp, key: keyApp},
+			{name: keyLevel, key: keyLevel},
+		},
+		labelKeys: make(map[string]struct{}),
+		lastTs:    make(map[string]int64),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	// the labels are sorted by name, so that a stream is always encoded the same, and the first label
+	// of a name is kept.
+	sort.SliceStable(l.labels, func(i, j int) bool {
+		return l.labels[i].name < l.labels[j].name
+	})
+	labels := l.labels[:0]
+	for i, label := range l.labels {
+		if i > 0 && label.name == labels[len(labels)-1].name {
+			continue
+		}
+		labels = append(labels, label)
+		if !label.static {
+			l.labelKeys[label.key] = struct{}{}
+		}
+	}
+	l.labels = labels
+
+	l.batch = batch.New(l.send, append(l.batchOpts, batch.WithWorkers(1))...)
+	return l
+}
+
+// Write copies the record encoded by Encode into the current batch. The records not encoded by Encode are
+// passed to the failure callback and an error is returned.
+func (l *Writer) Write(level olog.Level, p []byte) (n int, err error) {
+	if _, _, _, ok := splitRecord(p); !ok {
+		return 0, l.batch.Reject(p, olog.ErrNotEncoded)
+	}
+	l.batch.Add(p)
+	return len(p), nil
+}
+
+// Flush pushes the current batch and waits until all the batches are pushed.
+func (l *Writer) Flush() {
+	l.batch.Flush()
+}
+
+// Close pushes the remaining batches.
+func (l *Writer) Close() error {
+	return l.batch.Close()
+}
+
+// Encode encodes the Record as the stream labels, the timestamp in nanoseconds and the line separated
+// by tabs to the buffer. The line is a JSON object of the caller, the name of the logger, content, stack and
+// the fields which are not labels.
+func (l *Writer) Encode(r olog.Record, buf *encoder.Buffer) {
+	enc := encoder.JsonEncoder{Buffer: buf}
+
+	var sep bool
+	for _, label := range l.labels {
+		var value any
+		switch {
+		case label.static:
+			value = label.value
+		case label.key == keyApp:
+			if r.App == "" {
+				continue
+			}
+			value = r.App
+		case label.key == keyLogger:
+			if r.Name == "" {
+				continue
+			}
+			value = r.Name
+		case label.key == keyLevel:
+			value = r.LevelTag
+		default:
+			var ok bool
+			for _, field := range r.Fields {
+				if field.Key == label.key {
+					value, ok = field.Value, true
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		if sep {
+			enc.WriteSeparator()
+		}
+		sep = true
+
+		enc.WriteQuote()
+		_, _ = enc.WriteString(label.name)
+		_, _ = enc.WriteString(`":"`)
+		switch v := value.(type) {
+		case string:
+			if label.static || label.key == keyApp || label.key == keyLogger {
+				// the static value, the app and the name are escaped already.
+				_, _ = enc.WriteString(v)
+			} else {
+				enc.WriteEscapedString(v)
+			}
+		default:
+			enc.WriteEscapedString(fmt.Sprint(v))
+		}
+		enc.WriteQuote()
+	}
+
+	_ = enc.WriteByte('\t')
+	enc.WriteInt64(r.Time.UnixNano())
+	_ = enc.WriteByte('\t')
+
+	frame, frames, more := r.FirstFrame()
+
+	_ = enc.WriteByte('{')
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		_, _ = enc.WriteString(`"caller":"`)
+		enc.WriteEscapedString(file)
+		_ = enc.WriteByte(':')
+		enc.WriteInt64(int64(frame.Line))
+		_, _ = enc.WriteString(`",`)
+	}
+
+	if _, ok := l.labelKeys[keyLogger]; !ok && r.Name != "" {
+		_, _ = enc.WriteString(`"logger":"`)
+		_, _ = enc.WriteString(r.Name)
+		_, _ = enc.WriteString(`",`)
+	}
+
+	_, _ = enc.WriteString(`"content":"`)
+	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	enc.WriteQuote()
+
+	r.EachField(false, func(key string, field olog.Field) {
+		if _, ok := l.labelKeys[field.Key]; !ok {
+			enc.WriteSeparator()
+			enc.WriteName(key)
+			olog.WriteJSONValue(enc, field.Value, r.Duplicates)
+		}
+	})
+
+	if r.Stack.IsOpen() && frame.PC != 0 {
+		_, _ = enc.WriteString(`,"stack":"`)
+		olog.WriteStackText(buf, r.StackOpts, frame, frames, more, false, true)
+		enc.WriteQuote()
+	}
+	_ = enc.WriteByte('}')
+}
+
+// pushEntry is a log entry of a stream.
+type pushEntry struct {
+	ts   int64
+	line []byte
+}
+
+// pushStream is the entries of a stream in a batch.
+type pushStream struct {
+	labels  []byte
+	entries []pushEntry
+}
+
+// splitRecord splits the record encoded by Encode into the labels, the timestamp and the line, ok is
+// false if the record is not encoded by Encode.
+func splitRecord(p []byte) (labels []byte, ts int64, line []byte, ok bool) {
+	i := bytes.IndexByte(p, '\t')
+	if i < 0 {
+		return nil, 0, nil, false
+	}
+	j := bytes.IndexByte(p[i+1:], '\t')
+	if j < 0 {
+		return nil, 0, nil, false
+	}
+	j += i + 1
+	ts, err := strconv.ParseInt(string(p[i+1:j]), 10, 64)
+	if err != nil || j+1 >= len(p) || p[j+1] != '{' {
+		return nil, 0, nil, false
+	}
+	return p[:i], ts, p[j+1:], true
+}
+
+// send pushes the batch, the entries are grouped by stream, and the timestamps not after the last
+// timestamp of the stream are moved after it.
+func (l *Writer) send(records [][]byte) error {
+	var streams []*pushStream
+	for _, record := range records {
+		labels, ts, line, ok := splitRecord(record)
+		if !ok {
+			// the records not encoded by Encode are rejected by Write.
+			continue
+		}
+
+		var stream *pushStream
+		for _, s := range streams {
+			if string(s.labels) == string(labels) {
+				stream = s
+				break
+			}
+		}
+		if stream == nil {
+			stream = &pushStream{labels: labels}
+			streams = append(streams, stream)
+		}
+		stream.entries = append(stream.entries, pushEntry{ts: ts, line: line})
+	}
+	if len(streams) == 0 {
+		return nil
+	}
+
+	// the last timestamps are only updated after the push succeeded, so that a retried push is the same.
+	lastTs := make(map[string]int64, len(streams))
+	for _, stream := range streams {
+		last := l.lastTs[string(stream.labels)]
+		for i := range stream.entries {
+			if stream.entries[i].ts <= last {
+				stream.entries[i].ts = last + 1
+			}
+			last = stream.entries[i].ts
+		}
+		lastTs[string(stream.labels)] = last
+	}
+
+	buf := encoder.NewBuffer(make([]byte, 0, 4096))
+	writePush(encoder.JsonEncoder{Buffer: buf}, streams)
+
+	req, err := http.NewRequest(http.MethodPost, l.url, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	for key, values := range l.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		for labels, ts := range lastTs {
+			l.lastTs[labels] = ts
+		}
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("olog: loki push failed with status %s: %s", resp.Status, bytes.TrimSpace(body))
+	default:
+		return batch.Permanent(fmt.Errorf("olog: loki push failed with status %s: %s", resp.Status, bytes.TrimSpace(body)))
+	}
+}
+
+// writePush writes the push request of the streams.
+func writePush(enc encoder.JsonEncoder, streams []*pushStream) {
+	_, _ = enc.WriteString(`{"streams":[`)
+	for i, stream := range streams {
+		if i > 0 {
+			enc.WriteSeparator()
+		}
+
+		_, _ = enc.WriteString(`{"stream":{`)
+		_, _ = enc.Buffer.Write(stream.labels)
+		_, _ = enc.WriteString(`},"values":[`)
+		for j, entry := range stream.entries {
+			if j > 0 {
+				enc.WriteSeparator()
+			}
+			_, _ = enc.WriteString(`["`)
+			enc.WriteInt64(entry.ts)
+			_, _ = enc.WriteString(`","`)
+			// the line is escaped as a JSON string by the encoder.
+			_, _ = enc.Write(entry.line)
+			_, _ = enc.WriteString(`"]`)
+		}
+		_, _ = enc.WriteString(`]}`)
+	}
+	_, _ = enc.WriteString(`]}`)
+}
+
+// labelName returns the valid label name of the key, the characters not allowed are replaced by
+// underscores, and an underscore is prepended if it starts with a digit.
+func labelName(key string) string {
+	b := make([]byte, 0, len(key)+1)
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_':
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b = append(b, '_')
+			}
+		default:
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
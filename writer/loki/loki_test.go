@@ -0,0 +1,239 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/internal/testserver"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// pushRequest is the push request of the Loki push API in JSON.
+type pushRequest struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// newFakeLoki starts a recording server as a fake Loki server.
+func newFakeLoki(t *testing.T, statuses ...int) *testserver.Server {
+	return testserver.New(t, http.StatusNoContent, "", statuses...)
+}
+
+// recordedPushes decodes the push requests recorded by the fake Loki server.
+func pushRequests(t *testing.T, f *testserver.Server) []pushRequest {
+	var pushes []pushRequest
+	for _, req := range f.Requests() {
+		if req.Path != "/loki/api/v1/push" || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", req.Path, req.Header.Get("Content-Type"))
+		}
+		if tenant := req.Header.Get("X-Scope-OrgID"); tenant != "team" {
+			t.Errorf("X-Scope-OrgID = %q, want team", tenant)
+		}
+
+		var push pushRequest
+		if err := json.Unmarshal(req.Body, &push); err != nil {
+			t.Errorf("decode push: %v", err)
+		}
+		pushes = append(pushes, push)
+	}
+	return pushes
+}
+
+func TestWriter(t *testing.T) {
+	f := newFakeLoki(t)
+	w := New(f.URL+"/loki/api/v1/push",
+		WithTenant("team"),
+		WithLabelKeys("region", "http.method"),
+		WithStaticLabels(map[string]string{"env": "prod"}),
+	)
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode), olog.WithLoggerAppName("svc"))
+	logger.Infow("hello \"world\"", olog.Field{Key: "region", Value: "eu"}, olog.Field{Key: "http.method", Value: "GET"},
+		olog.Field{Key: "user", Value: "bob"}, olog.Field{Key: "latency", Value: 1.5})
+	logger.Infow("second", olog.Field{Key: "region", Value: "eu"}, olog.Field{Key: "http.method", Value: "GET"})
+	logger.Warnw("third", olog.Field{Key: "region", Value: 1})
+	w.Flush()
+
+	pushes := pushRequests(t, f)
+	if len(pushes) != 1 {
+		t.Fatalf("pushes = %d, want 1", len(pushes))
+	}
+
+	streams := pushes[0].Streams
+	if len(streams) != 2 {
+		t.Fatalf("streams = %d, want 2", len(streams))
+	}
+
+	want := map[string]string{"app": "svc", "env": "prod", "http_method": "GET", "level": "info", "region": "eu"}
+	if !reflect.DeepEqual(streams[0].Stream, want) {
+		t.Errorf("stream = %v, want %v", streams[0].Stream, want)
+	}
+	if len(streams[0].Values) != 2 {
+		t.Fatalf("values = %d, want 2", len(streams[0].Values))
+	}
+
+	ts, err := strconv.ParseInt(streams[0].Values[0][0], 10, 64)
+	if err != nil || time.Since(time.Unix(0, ts)) > 5*time.Second {
+		t.Errorf("timestamp = %s, err = %v", streams[0].Values[0][0], err)
+	}
+
+	line := make(map[string]any)
+	if err := json.Unmarshal([]byte(streams[0].Values[0][1]), &line); err != nil {
+		t.Fatalf("unmarshal line %s: %v", streams[0].Values[0][1], err)
+	}
+	wantLine := map[string]any{
+		"caller":  "loki/loki_test.go:63",
+		"content": `hello "world"`,
+		"user":    "bob",
+		"latency": 1.5,
+	}
+	if !reflect.DeepEqual(line, wantLine) {
+		t.Errorf("line = %v, want %v", line, wantLine)
+	}
+
+	want = map[string]string{"app": "svc", "env": "prod", "level": "warn", "region": "1"}
+	if !reflect.DeepEqual(streams[1].Stream, want) {
+		t.Errorf("stream = %v, want %v", streams[1].Stream, want)
+	}
+}
+
+func TestWriterOrdering(t *testing.T) {
+	f := newFakeLoki(t, http.StatusServiceUnavailable)
+	w := New(f.URL+"/loki/api/v1/push", WithTenant("team"),
+		WithBatch(batch.WithRetry(3, time.Millisecond, time.Millisecond)))
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode), olog.WithLoggerCaller(false))
+	now := time.Now()
+	logger.Log(olog.Record{Level: olog.INFO, MsgOrFormat: "first", Time: now})
+	logger.Log(olog.Record{Level: olog.INFO, MsgOrFormat: "second", Time: now.Add(-time.Second)})
+	w.Flush()
+
+	// a later batch is kept after the last timestamp of the stream.
+	logger.Log(olog.Record{Level: olog.INFO, MsgOrFormat: "third", Time: now})
+	w.Flush()
+
+	pushes := pushRequests(t, f)
+	if len(pushes) != 3 {
+		t.Fatalf("pushes = %d, want 3", len(pushes))
+	}
+	if !reflect.DeepEqual(pushes[0], pushes[1]) {
+		t.Errorf("retried push %v differs from %v", pushes[1], pushes[0])
+	}
+
+	ns := now.UnixNano()
+	want := [][2]string{
+		{strconv.FormatInt(ns, 10), `{"content":"first"}`},
+		{strconv.FormatInt(ns+1, 10), `{"content":"second"}`},
+	}
+	if got := pushes[1].Streams[0].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	want = [][2]string{{strconv.FormatInt(ns+2, 10), `{"content":"third"}`}}
+	if got := pushes[2].Streams[0].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+}
+
+func TestWriterRejected(t *testing.T) {
+	f := newFakeLoki(t, http.StatusBadRequest)
+
+	var failed int32
+	w := New(f.URL+"/loki/api/v1/push", WithTenant("team"), WithBatch(
+		batch.WithRetry(3, time.Millisecond, time.Millisecond),
+		batch.WithFailure(func(records [][]byte, err error) {
+			atomic.AddInt32(&failed, int32(len(records)))
+		}),
+	))
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode))
+	logger.Info("rejected")
+	w.Flush()
+
+	if n := len(pushRequests(t, f)); n != 1 {
+		t.Errorf("pushes = %d, want 1", n)
+	}
+	if n := atomic.LoadInt32(&failed); n != 1 {
+		t.Errorf("failed records = %d, want 1", n)
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	tests := map[string]string{
+		"region":      "region",
+		"http.method": "http_method",
+		"1st":         "_1st",
+		"a-b c":       "a_b_c",
+		"":            "_",
+	}
+	for key, want := range tests {
+		if got := labelName(key); got != want {
+			t.Errorf("labelName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestWriterNotEncoded(t *testing.T) {
+	var failed []error
+	w := New("http://127.0.0.1:1/loki/api/v1/push", WithBatch(batch.WithFailure(func(records [][]byte, err error) {
+		failed = append(failed, err)
+	})))
+	defer w.Close()
+
+	// the logger without the Encode of the writer as the EncodeFunc.
+	for _, encode := range []olog.EncodeType{olog.JSON, olog.PLAIN} {
+		logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncode(encode))
+		logger.Info("hello")
+	}
+	if _, err := w.Write(olog.INFO, []byte("hello\n")); err != olog.ErrNotEncoded {
+		t.Errorf("Write error = %v, want %v", err, olog.ErrNotEncoded)
+	}
+	w.Flush()
+
+	if len(failed) != 3 {
+		t.Fatalf("failed records = %d, want 3", len(failed))
+	}
+	for _, err := range failed {
+		if err != olog.ErrNotEncoded {
+			t.Errorf("failure error = %v, want %v", err, olog.ErrNotEncoded)
+		}
+	}
+}
+
+func TestWriterStackOptions(t *testing.T) {
+	w := New("http://127.0.0.1:1/loki/api/v1/push")
+	defer w.Close()
+
+	_, file, _, _ := runtime.Caller(0)
+	dir := file[:strings.LastIndexByte(file, '/')+1]
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.WithLoggerWriter(olog.NewWriter(&buf)), olog.WithLoggerCaller(false),
+		olog.WithLoggerEncodeFunc(w.Encode),
+		olog.WithLoggerStackOptions(&olog.StackOptions{SkipRuntime: true, TrimPrefixes: []string{dir}, Goroutine: true}))
+	logger.Log(olog.Record{Level: olog.ERROR, Stack: olog.Enable, StackSize: 10, MsgOrFormat: "failed"})
+
+	out := buf.String()
+	if !strings.Contains(out, `"stack":"goroutine `) {
+		t.Errorf("goroutine is not written in %q", out)
+	}
+	if !strings.Contains(out, `\tloki_test.go:`) {
+		t.Errorf("file is not trimmed in %q", out)
+	}
+	if strings.Contains(out, "testing.tRunner") {
+		t.Errorf("runtime frames are not skipped in %q", out)
+	}
+}
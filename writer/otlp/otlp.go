@@ -0,0 +1,438 @@
+// Package otlp provides the Writer exporting the log records of olog to an OpenTelemetry collector with
+// OTLP/HTTP JSON, without depending on the OpenTelemetry SDK.
+package otlp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/encoder"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// scopeName is the instrumentation scope name of the exported log records.
+const scopeName = "github.com/welllog/olog"
+
+// traceIDKeys and spanIDKeys are the field keys recognized as the trace id and span id, like the other
+// encoders of olog supporting trace correlation.
+var (
+	traceIDKeys = map[string]struct{}{"trace_id": {}, "trace.id": {}, "traceId": {}, "traceID": {}}
+	spanIDKeys  = map[string]struct{}{"span_id": {}, "span.id": {}, "spanId": {}, "spanID": {}}
+)
+
+// Writer is an olog.Writer that exports log records to an OpenTelemetry collector with OTLP/HTTP JSON.
+// Records are batched, grouped by the App as the service.name resource attribute, and the exports are
+// retried when the collector is unavailable or throttling.
+// It must be used together with its Encode method as the EncodeFunc of the logger:
+//
+//	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode))
+type Writer struct {
+	endpoint  string
+	client    *http.Client
+	headers   http.Header
+	resource  []olog.Field
+	batchOpts []batch.Option
+
+	batch *batch.Writer
+}
+
+// Option is a functional option type for configuring a Writer.
+type Option func(*Writer)
+
+// WithHeader adds a header to the export requests, such as the authorization of the collector.
+func WithHeader(key, value string) Option {
+	return func(o *Writer) {
+		o.headers.Add(key, value)
+	}
+}
+
+// WithClient sets the http client of the export requests, a client with 10 seconds timeout is used by default.
+func WithClient(client *http.Client) Option {
+	return func(o *Writer) {
+		o.client = client
+	}
+}
+
+// WithResource adds the resource attributes, such as service.version and deployment.environment,
+// to all the exported records.
+func WithResource(fields ...olog.Field) Option {
+	return func(o *Writer) {
+		o.resource = append(o.resource, fields...)
+	}
+}
+
+// WithBatch sets the batching options.
+func WithBatch(opts ...batch.Option) Option {
+	return func(o *Writer) {
+		o.batchOpts = append(o.batchOpts, opts...)
+	}
+}
+
+// New creates a new Writer exporting records to the OTLP/HTTP logs endpoint,
+// such as "http://localhost:4318/v1/logs".
+func New(endpoint string, opts ...Option) *Writer {
+	o := &Writer{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		headers:  make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.batch = batch.New(o.send, o.batchOpts...)
+	return o
+}
+
+// Write copies the record encoded by Encode into the current batch. The records not encoded by Encode are
+// passed to the failure callback and an error is returned.
+func (o *Writer) Write(level olog.Level, p []byte) (n int, err error) {
+	if _, _, ok := splitRecord(p); !ok {
+		return 0, o.batch.Reject(p, olog.ErrNotEncoded)
+	}
+	o.batch.Add(p)
+	return len(p), nil
+}
+
+// Flush exports the current batch and waits until all the batches are exported.
+func (o *Writer) Flush() {
+	o.batch.Flush()
+}
+
+// Close exports the remaining batches.
+func (o *Writer) Close() error {
+	return o.batch.Close()
+}
+
+// Encode encodes the Record as the App followed by a tab and the OTLP LogRecord in JSON to the buffer.
+// The level is mapped to the severityNumber, the fields to the attributes, and the fields such as
+// trace_id and span_id holding hex ids to the traceId and spanId.
+func (o *Writer) Encode(r olog.Record, buf *encoder.Buffer) {
+	enc := encoder.JsonEncoder{Buffer: buf}
+
+	_, _ = enc.WriteString(r.App)
+	_ = enc.WriteByte('\t')
+	_, _ = enc.WriteString(recordPrefix)
+	enc.WriteInt64(r.Time.UnixNano())
+	_, _ = enc.WriteString(`","severityNumber":`)
+	enc.WriteInt64(int64(severity(r.Level)))
+	_, _ = enc.WriteString(`,"severityText":"`)
+	_, _ = enc.WriteString(r.LevelTag)
+	_, _ = enc.WriteString(`","body":{"stringValue":"`)
+	_, _ = encoder.EPrintf(enc, r.MsgOrFormat, r.MsgArgs...)
+	_, _ = enc.WriteString(`"},"attributes":[`)
+
+	var attrs int
+	frame, frames, more := r.FirstFrame()
+	if r.Caller.IsOpen() {
+		file := r.CallerFile(frame)
+		_, _ = enc.WriteString(`{"key":"code.filepath","value":{"stringValue":"`)
+		enc.WriteEscapedString(file)
+		_, _ = enc.WriteString(`"}},{"key":"code.lineno","value":{"intValue":"`)
+		enc.WriteInt64(int64(frame.Line))
+		_, _ = enc.WriteString(`"}},{"key":"code.function","value":{"stringValue":"`)
+		enc.WriteEscapedString(frame.Function)
+		_, _ = enc.WriteString(`"}}`)
+		attrs++
+	}
+
+	if r.Name != "" {
+		if attrs > 0 {
+			enc.WriteSeparator()
+		}
+		_, _ = enc.WriteString(`{"key":"logger","value":{"stringValue":"`)
+		_, _ = enc.WriteString(r.Name)
+		_, _ = enc.WriteString(`"}}`)
+		attrs++
+	}
+
+	var traceID, spanID string
+	r.EachField(true, func(key string, field olog.Field) {
+		if traceID == "" {
+			if _, ok := traceIDKeys[key]; ok {
+				if traceID, ok = hexID(field.Value, 32); ok {
+					return
+				}
+			}
+		}
+		if spanID == "" {
+			if _, ok := spanIDKeys[key]; ok {
+				if spanID, ok = hexID(field.Value, 16); ok {
+					return
+				}
+			}
+		}
+
+		if attrs > 0 {
+			enc.WriteSeparator()
+		}
+		writeAttribute(enc, key, field.Value)
+		attrs++
+	})
+
+	if r.Stack.IsOpen() && frame.PC != 0 {
+		if attrs > 0 {
+			enc.WriteSeparator()
+		}
+		_, _ = enc.WriteString(`{"key":"code.stacktrace","value":{"stringValue":"`)
+		olog.WriteStackText(buf, r.StackOpts, frame, frames, more, false, true)
+		_, _ = enc.WriteString(`"}}`)
+	}
+	_ = enc.WriteByte(']')
+
+	if traceID != "" {
+		_, _ = enc.WriteString(`,"traceId":"`)
+		_, _ = enc.WriteString(traceID)
+		enc.WriteQuote()
+	}
+	if spanID != "" {
+		_, _ = enc.WriteString(`,"spanId":"`)
+		_, _ = enc.WriteString(spanID)
+		enc.WriteQuote()
+	}
+	_ = enc.WriteByte('}')
+}
+
+// resourceLogs is the log records of a resource in a batch.
+type resourceLogs struct {
+	app     []byte
+	records [][]byte
+}
+
+// send exports the batch as an ExportLogsServiceRequest with a ResourceLogs per App.
+func (o *Writer) send(records [][]byte) error {
+	var resources []*resourceLogs
+	for _, record := range records {
+		app, logRecord, ok := splitRecord(record)
+		if !ok {
+			// the records not encoded by Encode are rejected by Write.
+			continue
+		}
+
+		var res *resourceLogs
+		for _, r := range resources {
+			if string(r.app) == string(app) {
+				res = r
+				break
+			}
+		}
+		if res == nil {
+			res = &resourceLogs{app: app}
+			resources = append(resources, res)
+		}
+		res.records = append(res.records, logRecord)
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	buf := encoder.NewBuffer(make([]byte, 0, 4096))
+	o.writeRequest(encoder.JsonEncoder{Buffer: buf}, resources)
+
+	req, err := http.NewRequest(http.MethodPost, o.endpoint, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+	for key, values := range o.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("olog: otlp export failed with status %s", resp.Status)
+	default:
+		return batch.Permanent(fmt.Errorf("olog: otlp export failed with status %s", resp.Status))
+	}
+}
+
+// writeRequest writes the ExportLogsServiceRequest of the resources.
+func (o *Writer) writeRequest(enc encoder.JsonEncoder, resources []*resourceLogs) {
+	_, _ = enc.WriteString(`{"resourceLogs":[`)
+	for i, res := range resources {
+		if i > 0 {
+			enc.WriteSeparator()
+		}
+
+		_, _ = enc.WriteString(`{"resource":{"attributes":[`)
+		attrs := 0
+		if len(res.app) > 0 {
+			_, _ = enc.WriteString(`{"key":"service.name","value":{"stringValue":"`)
+			_, _ = enc.Buffer.Write(res.app)
+			_, _ = enc.WriteString(`"}}`)
+			attrs++
+		}
+		for _, field := range o.resource {
+			if field.Key == "service.name" && len(res.app) > 0 {
+				continue
+			}
+			if attrs > 0 {
+				enc.WriteSeparator()
+			}
+			writeAttribute(enc, field.Key, field.Value)
+			attrs++
+		}
+
+		_, _ = enc.WriteString(`]},"scopeLogs":[{"scope":{"name":"` + scopeName + `"},"logRecords":[`)
+		for j, record := range res.records {
+			if j > 0 {
+				enc.WriteSeparator()
+			}
+			_, _ = enc.Buffer.Write(record)
+		}
+		_, _ = enc.WriteString(`]}]}`)
+	}
+	_, _ = enc.WriteString(`]}`)
+}
+
+// writeAttribute writes the key and value as an OTLP KeyValue, the value types which have no
+// corresponding AnyValue are written as strings.
+func writeAttribute(enc encoder.JsonEncoder, key string, value any) {
+	_, _ = enc.WriteString(`{"key":"`)
+	enc.WriteEscapedString(key)
+	_, _ = enc.WriteString(`","value":{`)
+
+	switch v := value.(type) {
+	case nil:
+	case bool:
+		_, _ = enc.WriteString(`"boolValue":`)
+		enc.WriteBool(v)
+	case int:
+		writeInt(enc, int64(v))
+	case int8:
+		writeInt(enc, int64(v))
+	case int16:
+		writeInt(enc, int64(v))
+	case int32:
+		writeInt(enc, int64(v))
+	case int64:
+		writeInt(enc, v)
+	case uint:
+		writeUint(enc, uint64(v))
+	case uint8:
+		writeUint(enc, uint64(v))
+	case uint16:
+		writeUint(enc, uint64(v))
+	case uint32:
+		writeUint(enc, uint64(v))
+	case uint64:
+		writeUint(enc, v)
+	case float32:
+		_, _ = enc.WriteString(`"doubleValue":`)
+		enc.WriteFloat(float64(v), 32)
+	case float64:
+		_, _ = enc.WriteString(`"doubleValue":`)
+		enc.WriteFloat(v, 64)
+	case []byte:
+		_, _ = enc.WriteString(`"bytesValue":`)
+		enc.WriteValue(v)
+	default:
+		// the other values are written as json strings.
+		_, _ = enc.WriteString(`"stringValue":`)
+		enc.WriteValue(v)
+	}
+	_, _ = enc.WriteString(`}}`)
+}
+
+// writeInt writes the int64 AnyValue, which is a decimal string in OTLP JSON.
+func writeInt(enc encoder.JsonEncoder, n int64) {
+	_, _ = enc.WriteString(`"intValue":"`)
+	enc.WriteInt64(n)
+	enc.WriteQuote()
+}
+
+// writeUint writes the uint64 as an int64 AnyValue, or as a string if it overflows.
+func writeUint(enc encoder.JsonEncoder, n uint64) {
+	if n > math.MaxInt64 {
+		_, _ = enc.WriteString(`"stringValue":"`)
+	} else {
+		_, _ = enc.WriteString(`"intValue":"`)
+	}
+	enc.WriteUint64(n)
+	enc.WriteQuote()
+}
+
+// hexID returns the value as a lower case hex id of size characters, such as the string or the
+// fmt.Stringer value of a trace id, it reports false if the value is not a valid id.
+func hexID(value any, size int) (string, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return "", false
+	}
+
+	if len(s) != size {
+		return "", false
+	}
+	zero := true
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'f':
+		case c >= 'A' && c <= 'F':
+			b[i] = c + 'a' - 'A'
+		default:
+			return "", false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	if zero {
+		return "", false
+	}
+	return string(b), true
+}
+
+// recordPrefix is the prefix of the log records encoded by Encode.
+const recordPrefix = `{"timeUnixNano":"`
+
+// splitRecord splits the record encoded by Encode into the App and the log record, ok is false if the
+// record is not encoded by Encode.
+func splitRecord(p []byte) (app, logRecord []byte, ok bool) {
+	i := bytes.IndexByte(p, '\t')
+	if i < 0 || !bytes.HasPrefix(p[i+1:], []byte(recordPrefix)) {
+		return nil, nil, false
+	}
+	return p[:i], p[i+1:], true
+}
+
+// severity returns the OTLP severity number of the level.
+func severity(level olog.Level) int {
+	switch severity := level.Severity(); {
+	case severity >= olog.FATAL.Severity():
+		return 21 // FATAL
+	case severity >= olog.ERROR.Severity():
+		return 17 // ERROR
+	case severity >= olog.WARN.Severity():
+		return 13 // WARN
+	case severity >= olog.NOTICE.Severity():
+		return 10 // INFO2
+	case severity >= olog.INFO.Severity():
+		return 9 // INFO
+	case severity >= olog.DEBUG.Severity():
+		return 5 // DEBUG
+	default:
+		return 1 // TRACE
+	}
+}
@@ -0,0 +1,242 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog"
+	"github.com/welllog/olog/internal/testserver"
+	"github.com/welllog/olog/writer/batch"
+)
+
+// newCollector starts a recording server as a fake OTLP/HTTP collector.
+func newCollector(t *testing.T, statuses ...int) *testserver.Server {
+	return testserver.New(t, http.StatusOK, "{}", statuses...)
+}
+
+// exportRequests decodes the export requests recorded by the fake collector.
+func exportRequests(t *testing.T, c *testserver.Server) []map[string]any {
+	var reqs []map[string]any
+	for _, r := range c.Requests() {
+		if r.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Path, r.Header.Get("Content-Type"))
+		}
+
+		req := make(map[string]any)
+		if err := json.Unmarshal(r.Body, &req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+func TestWriter(t *testing.T) {
+	c := newCollector(t)
+	w := New(c.URL+"/v1/logs",
+		WithHeader("Authorization", "Bearer token"),
+		WithResource(olog.Field{Key: "deployment.environment", Value: "test"}),
+	)
+	defer w.Close()
+
+	svc := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode), olog.WithLoggerAppName("svc"))
+	other := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode), olog.WithLoggerAppName("other"), olog.WithLoggerCaller(false))
+
+	svc.Warnw("hello \"world\"",
+		olog.Field{Key: "trace_id", Value: "4BF92F3577B34DA6A3CE929D0E0E4736"},
+		olog.Field{Key: "span_id", Value: "00f067aa0ba902b7"},
+		olog.Field{Key: "count", Value: 3},
+		olog.Field{Key: "ok", Value: true},
+		olog.Field{Key: "ratio", Value: 0.5},
+		olog.Field{Key: "err", Value: errors.New("boom")},
+	)
+	other.Infow("second", olog.Field{Key: "trace_id", Value: "not a trace id"})
+	svc.Error("third")
+	w.Flush()
+
+	reqs := exportRequests(t, c)
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+
+	resourceLogs := reqs[0]["resourceLogs"].([]any)
+	if len(resourceLogs) != 2 {
+		t.Fatalf("resourceLogs = %d, want 2", len(resourceLogs))
+	}
+
+	rl := resourceLogs[0].(map[string]any)
+	wantResource := map[string]any{"attributes": []any{
+		map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "svc"}},
+		map[string]any{"key": "deployment.environment", "value": map[string]any{"stringValue": "test"}},
+	}}
+	if !reflect.DeepEqual(rl["resource"], wantResource) {
+		t.Errorf("resource = %v, want %v", rl["resource"], wantResource)
+	}
+
+	scopeLogs := rl["scopeLogs"].([]any)[0].(map[string]any)
+	if name := scopeLogs["scope"].(map[string]any)["name"]; name != scopeName {
+		t.Errorf("scope name = %v", name)
+	}
+	records := scopeLogs["logRecords"].([]any)
+	if len(records) != 2 {
+		t.Fatalf("logRecords = %d, want 2", len(records))
+	}
+
+	record := records[0].(map[string]any)
+	ts, _ := record["timeUnixNano"].(string)
+	if len(ts) != 19 {
+		t.Errorf("timeUnixNano = %v", record["timeUnixNano"])
+	}
+	delete(record, "timeUnixNano")
+
+	attrs := record["attributes"].([]any)
+	if len(attrs) != 7 {
+		t.Fatalf("attributes = %v", attrs)
+	}
+	if line := attrs[1].(map[string]any)["value"]; !reflect.DeepEqual(line, map[string]any{"intValue": "53"}) {
+		t.Errorf("code.lineno = %v, want 53", line)
+	}
+
+	want := map[string]any{
+		"severityNumber": float64(13),
+		"severityText":   "warn",
+		"body":           map[string]any{"stringValue": `hello "world"`},
+		"attributes": []any{
+			map[string]any{"key": "code.filepath", "value": map[string]any{"stringValue": "otlp/otlp_test.go"}},
+			attrs[1],
+			map[string]any{"key": "code.function", "value": map[string]any{"stringValue": "github.com/welllog/olog/writer/otlp.TestWriter"}},
+			map[string]any{"key": "count", "value": map[string]any{"intValue": "3"}},
+			map[string]any{"key": "ok", "value": map[string]any{"boolValue": true}},
+			map[string]any{"key": "ratio", "value": map[string]any{"doubleValue": 0.5}},
+			map[string]any{"key": "err", "value": map[string]any{"stringValue": "boom"}},
+		},
+		"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"spanId":  "00f067aa0ba902b7",
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("logRecord = %v, want %v", record, want)
+	}
+
+	if body := records[1].(map[string]any)["body"]; !reflect.DeepEqual(body, map[string]any{"stringValue": "third"}) {
+		t.Errorf("body = %v", body)
+	}
+
+	record = resourceLogs[1].(map[string]any)["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)[0].(map[string]any)
+	if _, ok := record["traceId"]; ok {
+		t.Errorf("invalid trace id is exported as traceId")
+	}
+	wantAttrs := []any{map[string]any{"key": "trace_id", "value": map[string]any{"stringValue": "not a trace id"}}}
+	if !reflect.DeepEqual(record["attributes"], wantAttrs) {
+		t.Errorf("attributes = %v, want %v", record["attributes"], wantAttrs)
+	}
+}
+
+func TestWriterRetry(t *testing.T) {
+	c := newCollector(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK, http.StatusBadRequest)
+
+	var failed int32
+	w := New(c.URL+"/v1/logs", WithBatch(
+		batch.WithRetry(5, time.Millisecond, time.Millisecond),
+		batch.WithFailure(func(records [][]byte, err error) {
+			atomic.AddInt32(&failed, int32(len(records)))
+		}),
+	))
+	defer w.Close()
+
+	logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncodeFunc(w.Encode))
+	logger.Info("retried")
+	w.Flush()
+
+	if n := len(exportRequests(t, c)); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+	if n := atomic.LoadInt32(&failed); n != 0 {
+		t.Errorf("failed records = %d, want 0", n)
+	}
+
+	// 400 is not retryable.
+	logger.Info("rejected")
+	w.Flush()
+
+	if n := len(exportRequests(t, c)); n != 4 {
+		t.Errorf("requests = %d, want 4", n)
+	}
+	if n := atomic.LoadInt32(&failed); n != 1 {
+		t.Errorf("failed records = %d, want 1", n)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level olog.Level
+		want  int
+	}{
+		{olog.TRACE, 1}, {olog.DEBUG, 5}, {olog.INFO, 9}, {olog.NOTICE, 10}, {olog.WARN, 13}, {olog.ERROR, 17},
+		{olog.FATAL, 21}, {olog.FATAL + 1, 21},
+	}
+	for _, tt := range tests {
+		if got := severity(tt.level); got != tt.want {
+			t.Errorf("severity(%s) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestWriterNotEncoded(t *testing.T) {
+	var failed []error
+	w := New("http://127.0.0.1:1/v1/logs", WithBatch(batch.WithFailure(func(records [][]byte, err error) {
+		failed = append(failed, err)
+	})))
+	defer w.Close()
+
+	// the logger without the Encode of the writer as the EncodeFunc.
+	for _, encode := range []olog.EncodeType{olog.JSON, olog.PLAIN} {
+		logger := olog.NewLogger(olog.WithLoggerWriter(w), olog.WithLoggerEncode(encode))
+		logger.Info("hello")
+	}
+	if _, err := w.Write(olog.INFO, []byte("hello\n")); err != olog.ErrNotEncoded {
+		t.Errorf("Write error = %v, want %v", err, olog.ErrNotEncoded)
+	}
+	w.Flush()
+
+	if len(failed) != 3 {
+		t.Fatalf("failed records = %d, want 3", len(failed))
+	}
+	for _, err := range failed {
+		if err != olog.ErrNotEncoded {
+			t.Errorf("failure error = %v, want %v", err, olog.ErrNotEncoded)
+		}
+	}
+}
+
+func TestWriterStackOptions(t *testing.T) {
+	w := New("http://127.0.0.1:1/v1/logs")
+	defer w.Close()
+
+	_, file, _, _ := runtime.Caller(0)
+	dir := file[:strings.LastIndexByte(file, '/')+1]
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.WithLoggerWriter(olog.NewWriter(&buf)), olog.WithLoggerCaller(false),
+		olog.WithLoggerEncodeFunc(w.Encode),
+		olog.WithLoggerStackOptions(&olog.StackOptions{SkipRuntime: true, TrimPrefixes: []string{dir}, Goroutine: true}))
+	logger.Log(olog.Record{Level: olog.ERROR, Stack: olog.Enable, StackSize: 10, MsgOrFormat: "failed"})
+
+	out := buf.String()
+	if !strings.Contains(out, `{"key":"code.stacktrace","value":{"stringValue":"goroutine `) {
+		t.Errorf("goroutine is not written in %q", out)
+	}
+	if !strings.Contains(out, `\totlp_test.go:`) {
+		t.Errorf("file is not trimmed in %q", out)
+	}
+	if strings.Contains(out, "testing.tRunner") {
+		t.Errorf("runtime frames are not skipped in %q", out)
+	}
+}
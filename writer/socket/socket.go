@@ -0,0 +1,417 @@
+// Package socket provides the Writer sending the log records of olog to a TCP or unix socket peer, such as
+// the tcp input of Fluent Bit, Vector or Logstash, with reconnection and spooling.
+package socket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/welllog/olog"
+)
+
+// Stats is the health and lag statistics of a Writer.
+type Stats struct {
+	Connected    bool      // Connected is whether the writer is connected to the peer.
+	Reconnects   uint64    // Reconnects is the number of successful reconnections.
+	Sent         uint64    // Sent is the number of bytes sent to the peer.
+	Spooled      int64     // Spooled is the number of bytes in the spool waiting to be sent.
+	Dropped      uint64    // Dropped is the number of records dropped because the spool is full or absent.
+	LastError    error     // LastError is the last error of connecting or sending.
+	Disconnected time.Time // Disconnected is the time the writer was disconnected, zero if connected.
+}
+
+// Writer is an olog.Writer that sends newline framed records to a TCP or unix socket peer, such as the tcp input
+// of Fluent Bit, Vector or Logstash. It reconnects with exponential backoff when the connection fails, and
+// spools the records to a bounded local file while the peer is unavailable, which are replayed in order once
+// reconnected.
+type Writer struct {
+	network    string
+	addr       string
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	spoolPath  string
+	spoolMax   int64
+
+	mu        sync.Mutex
+	conn      net.Conn
+	spool     *os.File
+	spoolHead int64 // spoolHead is the offset of the first record not sent in the spool
+	spoolTail int64 // spoolTail is the offset of the end of the spool
+	partial   bool  // partial is whether the record at spoolHead was partially sent, its rest is skipped
+	stats     Stats
+	connected bool // connected is whether the writer has ever connected to the peer
+	closed    bool
+	wake      chan struct{}
+	done      chan struct{}
+}
+
+// Option is a functional option type for configuring a Writer.
+type Option func(*Writer)
+
+// WithTLS sets the TLS configuration, the connection is established with TLS if it is not nil.
+func WithTLS(config *tls.Config) Option {
+	return func(n *Writer) {
+		n.tlsConfig = config
+	}
+}
+
+// WithTimeout sets the timeout of dialing and writing, 5 seconds is used by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(n *Writer) {
+		n.timeout = timeout
+	}
+}
+
+var (
+	// errClosed is returned by Write after the writer is closed.
+	errClosed = errors.New("olog: socket writer is closed")
+	// errTruncated is the error of the spool truncated by others, the records left in it are lost.
+	errTruncated = errors.New("olog: socket writer spool is truncated")
+)
+
+// minDelay is the minimum delay between reconnection attempts, so that the writer never busy spins.
+const minDelay = time.Millisecond
+
+// WithBackoff sets the minimum and maximum delay between reconnection attempts,
+// the delay doubles after each failed attempt. 100ms and 30s are used by default.
+// The delays are at least 1ms, and the maximum is at least the minimum.
+func WithBackoff(min, max time.Duration) Option {
+	if min < minDelay {
+		min = minDelay
+	}
+	if max < min {
+		max = min
+	}
+	return func(n *Writer) {
+		n.minBackoff = min
+		n.maxBackoff = max
+	}
+}
+
+// WithSpool sets the path and maximum size of the spool file, records are spooled to the file while
+// the peer is unavailable. Records are dropped instead if the spool is not set or full.
+// Records left in the file by a previous process are sent first.
+func WithSpool(path string, maxBytes int64) Option {
+	return func(n *Writer) {
+		n.spoolPath = path
+		n.spoolMax = maxBytes
+	}
+}
+
+// New creates a new Writer sending records to the peer at the network address, the network must
+// be a stream network such as "tcp" or "unix". It connects to the peer in background, so it only returns an
+// error when the spool file cannot be opened.
+func New(network, addr string, opts ...Option) (*Writer, error) {
+	n := &Writer{
+		network:    network,
+		addr:       addr,
+		timeout:    5 * time.Second,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	if n.spoolPath != "" {
+		f, err := os.OpenFile(n.spoolPath, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		n.spool = f
+		n.spoolTail = fi.Size()
+		n.stats.Spooled = n.spoolTail
+	}
+
+	n.stats.Disconnected = time.Now()
+	go n.run()
+	return n, nil
+}
+
+// Write sends the record to the peer, or spools it while the peer is unavailable.
+// A newline is appended to the record if it does not end with one.
+func (n *Writer) Write(level olog.Level, p []byte) (int, error) {
+	buf := p
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		buf = append(append(make([]byte, 0, len(p)+1), p...), '\n')
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return 0, errClosed
+	}
+
+	// records are sent directly only if there are no spooled records, to keep them in order.
+	if n.conn != nil && n.spoolHead == n.spoolTail {
+		_ = n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+		m, err := n.conn.Write(buf)
+		n.stats.Sent += uint64(m)
+		if err == nil {
+			return len(p), nil
+		}
+		n.disconnect(err)
+
+		if m > 0 {
+			// the record is partially sent over the broken connection, sending it again would duplicate
+			// the part, so it is lost.
+			n.stats.Dropped++
+			return len(p), nil
+		}
+	}
+
+	n.spoolRecord(buf)
+	return len(p), nil
+}
+
+// Stats returns the health and lag statistics.
+func (n *Writer) Stats() Stats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.stats
+}
+
+// Close stops reconnecting and closes the connection and the spool file.
+// Records not sent are kept in the spool file.
+func (n *Writer) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.done)
+
+	var err error
+	if n.conn != nil {
+		err = n.conn.Close()
+		n.conn = nil
+	}
+	if n.spool != nil {
+		if e := n.spool.Close(); err == nil {
+			err = e
+		}
+	}
+	n.mu.Unlock()
+
+	return err
+}
+
+// disconnect closes the broken connection and wakes the reconnection loop, the caller must hold the lock.
+func (n *Writer) disconnect(err error) {
+	_ = n.conn.Close()
+	n.conn = nil
+	n.stats.Connected = false
+	n.stats.LastError = err
+	n.stats.Disconnected = time.Now()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// spoolRecord appends the record to the spool, the caller must hold the lock. The record is dropped if the
+// records not sent would exceed the maximum size, and the spool is compacted if the file would.
+func (n *Writer) spoolRecord(p []byte) {
+	if n.spool == nil || n.spoolTail-n.spoolHead+int64(len(p)) > n.spoolMax {
+		n.stats.Dropped++
+		return
+	}
+	if n.spoolTail+int64(len(p)) > n.spoolMax {
+		if err := n.compact(); err != nil {
+			n.stats.Dropped++
+			n.stats.LastError = err
+			return
+		}
+	}
+
+	if _, err := n.spool.WriteAt(p, n.spoolTail); err != nil {
+		n.stats.Dropped++
+		n.stats.LastError = err
+		return
+	}
+	n.spoolTail += int64(len(p))
+	n.stats.Spooled = n.spoolTail - n.spoolHead
+}
+
+// compact moves the records not sent to the start of the spool file, the caller must hold the lock.
+// The offsets of the records relative to spoolHead are kept, so that replay advancing spoolHead by the
+// bytes sent concurrently is not affected.
+func (n *Writer) compact() error {
+	chunk := make([]byte, 32*1024)
+	size := n.spoolTail - n.spoolHead
+	for off := int64(0); off < size; {
+		end := off + int64(len(chunk))
+		if end > size {
+			end = size
+		}
+		m, err := n.spool.ReadAt(chunk[:end-off], n.spoolHead+off)
+		if m == 0 {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = errTruncated
+			}
+			return err
+		}
+		if _, err = n.spool.WriteAt(chunk[:m], off); err != nil {
+			return err
+		}
+		off += int64(m)
+	}
+
+	if err := n.spool.Truncate(size); err != nil {
+		return err
+	}
+	n.spoolHead, n.spoolTail = 0, size
+	return nil
+}
+
+// run connects to the peer and reconnects with exponential backoff when the connection is broken.
+func (n *Writer) run() {
+	backoff := n.minBackoff
+	for {
+		conn, err := n.dial()
+		if err == nil {
+			err = n.replay(conn)
+			if err == nil {
+				backoff = n.minBackoff
+
+				// wait for the connection to be broken.
+				select {
+				case <-n.wake:
+					continue
+				case <-n.done:
+					return
+				}
+			}
+			_ = conn.Close()
+		}
+
+		n.mu.Lock()
+		n.stats.LastError = err
+		n.mu.Unlock()
+
+		// sleep with jitter, so that many writers do not reconnect at the same time.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-n.done:
+			timer.Stop()
+			return
+		}
+
+		backoff *= 2
+		if backoff > n.maxBackoff {
+			backoff = n.maxBackoff
+		}
+	}
+}
+
+// dial connects to the peer.
+func (n *Writer) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: n.timeout}
+	if n.tlsConfig != nil {
+		return tls.DialWithDialer(dialer, n.network, n.addr, n.tlsConfig)
+	}
+	return dialer.Dial(n.network, n.addr)
+}
+
+// replay sends the spooled records over the new connection, then makes the connection available to Write.
+func (n *Writer) replay(conn net.Conn) error {
+	chunk := make([]byte, 32*1024)
+	for {
+		n.mu.Lock()
+		if n.closed {
+			n.mu.Unlock()
+			return errClosed
+		}
+
+		if n.spoolHead == n.spoolTail {
+			// all the spooled records are sent, reset the spool.
+			if n.spool != nil && n.spoolTail > 0 {
+				_ = n.spool.Truncate(0)
+			}
+			n.spoolHead, n.spoolTail = 0, 0
+			n.partial = false
+			n.stats.Spooled = 0
+
+			n.conn = conn
+			if n.connected {
+				n.stats.Reconnects++
+			}
+			n.connected = true
+			n.stats.Connected = true
+			n.stats.Disconnected = time.Time{}
+			n.mu.Unlock()
+			return nil
+		}
+
+		size := n.spoolTail - n.spoolHead
+		if size > int64(len(chunk)) {
+			size = int64(len(chunk))
+		}
+		m, err := n.spool.ReadAt(chunk[:size], n.spoolHead)
+		if m == 0 && errors.Is(err, io.EOF) {
+			// the spool is truncated by others, the records left in it are lost.
+			n.spoolHead = n.spoolTail
+			n.stats.LastError = errTruncated
+			n.mu.Unlock()
+			continue
+		}
+		n.mu.Unlock()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		data := chunk[:m]
+		if n.partial {
+			// skip the rest of the record partially sent over the previous connection.
+			i := bytes.IndexByte(data, '\n')
+			n.mu.Lock()
+			if i < 0 {
+				n.spoolHead += int64(m)
+			} else {
+				n.spoolHead += int64(i + 1)
+				n.partial = false
+			}
+			n.stats.Spooled = n.spoolTail - n.spoolHead
+			n.mu.Unlock()
+			continue
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(n.timeout))
+		sent, err := conn.Write(data)
+
+		n.mu.Lock()
+		n.spoolHead += int64(sent)
+		n.stats.Sent += uint64(sent)
+		if err != nil && sent > 0 && data[sent-1] != '\n' {
+			// the record is partially sent, sending its rest over the next connection would corrupt it.
+			n.partial = true
+			n.stats.Dropped++
+		}
+		n.stats.Spooled = n.spoolTail - n.spoolHead
+		n.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package socket
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/welllog/olog"
+)
+
+// lineServer is a TCP server collecting the received lines, it can drop its connections.
+type lineServer struct {
+	t     *testing.T
+	addr  string
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+	lines chan string
+}
+
+func newLineServer(t *testing.T, addr string) *lineServer {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &lineServer{t: t, addr: ln.Addr().String(), ln: ln, lines: make(chan string, 1000)}
+	go s.serve()
+	return s
+}
+
+func (s *lineServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go func() {
+			sc := bufio.NewScanner(conn)
+			for sc.Scan() {
+				s.lines <- sc.Text()
+			}
+		}()
+	}
+}
+
+// stop closes the listener and drops the connections.
+func (s *lineServer) stop() {
+	_ = s.ln.Close()
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+}
+
+// expect checks the lines from the number from to the number to are received in order,
+// the lines written while the writer has not noticed the broken connection are skipped.
+func (s *lineServer) expect(from, to int) {
+	for i := from; i < to; i++ {
+		select {
+		case line := <-s.lines:
+			if line == "lost" {
+				i--
+				continue
+			}
+			if line != strconv.Itoa(i) {
+				s.t.Fatalf("line = %s, want %d", line, i)
+			}
+		case <-time.After(5 * time.Second):
+			s.t.Fatalf("timeout waiting for line %d", i)
+		}
+	}
+}
+
+func TestWriterSpool(t *testing.T) {
+	// reserve an address and keep the peer unavailable.
+	server := newLineServer(t, "127.0.0.1:0")
+	addr := server.addr
+	server.stop()
+
+	w, err := New("tcp", addr,
+		WithSpool(filepath.Join(t.TempDir(), "spool.log"), 1024),
+		WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		_, _ = w.Write(olog.INFO, []byte(strconv.Itoa(i)))
+	}
+	stats := w.Stats()
+	if stats.Connected || stats.Spooled != 20 {
+		t.Fatalf("stats = %+v, want spooled 20 bytes", stats)
+	}
+
+	server = newLineServer(t, addr)
+	defer server.stop()
+	server.expect(0, 10)
+
+	for i := 10; i < 20; i++ {
+		_, _ = w.Write(olog.INFO, []byte(strconv.Itoa(i)+"\n"))
+	}
+	server.expect(10, 20)
+
+	stats = w.Stats()
+	if !stats.Connected || stats.Spooled != 0 || stats.Dropped != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestWriterReconnect(t *testing.T) {
+	server := newLineServer(t, "127.0.0.1:0")
+
+	w, err := New("tcp", server.addr,
+		WithSpool(filepath.Join(t.TempDir(), "spool.log"), 1<<20),
+		WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	waitConnected := func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for !w.Stats().Connected {
+			if time.Now().After(deadline) {
+				t.Fatal("timeout waiting for connection")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitConnected()
+	_, _ = w.Write(olog.INFO, []byte("0"))
+	server.expect(0, 1)
+
+	server.stop()
+
+	// a write to a connection closed by the peer may succeed, so write until the writer notices it.
+	i := 1
+	for w.Stats().Connected {
+		_, _ = w.Write(olog.INFO, []byte("lost"))
+		time.Sleep(5 * time.Millisecond)
+	}
+	for ; i < 5; i++ {
+		_, _ = w.Write(olog.INFO, []byte(strconv.Itoa(i)))
+	}
+
+	server = newLineServer(t, server.addr)
+	defer server.stop()
+	server.expect(1, 5)
+
+	if stats := w.Stats(); stats.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", stats.Reconnects)
+	}
+}
+
+func TestWriterDrop(t *testing.T) {
+	server := newLineServer(t, "127.0.0.1:0")
+	server.stop()
+
+	w, err := New("tcp", server.addr, WithSpool(filepath.Join(t.TempDir(), "spool.log"), 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write(olog.INFO, []byte("1"))
+	_, _ = w.Write(olog.INFO, []byte("2"))
+	_, _ = w.Write(olog.INFO, []byte("3"))
+	if stats := w.Stats(); stats.Spooled != 4 || stats.Dropped != 1 {
+		t.Errorf("stats = %+v, want spooled 4 and dropped 1", stats)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(olog.INFO, []byte("4")); err == nil {
+		t.Error("expected error writing to closed writer")
+	}
+}
+
+func TestWriterSpoolTruncated(t *testing.T) {
+	server := newLineServer(t, "127.0.0.1:0")
+	addr := server.addr
+	server.stop()
+
+	spool := filepath.Join(t.TempDir(), "spool.log")
+	w, err := New("tcp", addr, WithSpool(spool, 1024), WithBackoff(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		_, _ = w.Write(olog.INFO, []byte(strconv.Itoa(i)))
+	}
+	if err := os.Truncate(spool, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	server = newLineServer(t, addr)
+	defer server.stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Stats().Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for connection")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_, _ = w.Write(olog.INFO, []byte("10"))
+	server.expect(10, 11)
+
+	if stats := w.Stats(); stats.Spooled != 0 || stats.LastError == nil {
+		t.Errorf("stats = %+v, want the truncated spool skipped", stats)
+	}
+}
+
+// limitConn is a connection accepting limit bytes and failing the writes after them.
+type limitConn struct {
+	net.Conn
+	limit int
+	buf   []byte
+}
+
+func (c *limitConn) Write(p []byte) (int, error) {
+	if len(c.buf)+len(p) <= c.limit {
+		c.buf = append(c.buf, p...)
+		return len(p), nil
+	}
+	n := c.limit - len(c.buf)
+	c.buf = append(c.buf, p[:n]...)
+	return n, errors.New("broken pipe")
+}
+
+func (c *limitConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *limitConn) Close() error { return nil }
+
+// newSpoolWriter returns a Writer with the spool but without connecting, to drive it directly.
+func newSpoolWriter(t *testing.T, max int64) *Writer {
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "spool.log"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return &Writer{spool: f, spoolMax: max, timeout: time.Second, wake: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+func TestWriterPartialWrite(t *testing.T) {
+	n := newSpoolWriter(t, 1024)
+	n.conn = &limitConn{limit: 3}
+	_, _ = n.Write(olog.INFO, []byte("hello\n"))
+
+	// the partially sent record is neither spooled nor sent again.
+	if stats := n.Stats(); stats.Sent != 3 || stats.Dropped != 1 || stats.Spooled != 0 {
+		t.Errorf("stats = %+v, want sent 3, dropped 1 and nothing spooled", stats)
+	}
+
+	n.spoolRecord([]byte("aaa\nbbb\n"))
+	n.spoolRecord([]byte("ccc\n"))
+	if err := n.replay(&limitConn{limit: 6}); err == nil {
+		t.Fatal("replay expected error")
+	}
+
+	conn := &limitConn{limit: 1024}
+	if err := n.replay(conn); err != nil {
+		t.Fatal(err)
+	}
+	if string(conn.buf) != "ccc\n" {
+		t.Errorf("replayed %q, want the records after the partially sent one", conn.buf)
+	}
+	if stats := n.Stats(); stats.Dropped != 2 || stats.Spooled != 0 {
+		t.Errorf("stats = %+v, want dropped 2 and nothing spooled", stats)
+	}
+}
+
+func TestWriterSpoolCompact(t *testing.T) {
+	n := newSpoolWriter(t, 10)
+	n.spoolRecord([]byte("1234\n"))
+	n.spoolRecord([]byte("5678\n"))
+
+	// the first record is sent, the spool keeps the size of the records not sent.
+	n.spoolHead = 5
+	n.spoolRecord([]byte("abcd\n"))
+
+	if stats := n.Stats(); stats.Dropped != 0 || stats.Spooled != 10 {
+		t.Errorf("stats = %+v, want spooled 10 and nothing dropped", stats)
+	}
+	b, err := os.ReadFile(n.spool.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "5678\nabcd\n" || n.spoolHead != 0 {
+		t.Errorf("spool = %q from %d, want the records not sent compacted", b, n.spoolHead)
+	}
+
+	n.spoolRecord([]byte("x\n"))
+	if stats := n.Stats(); stats.Dropped != 1 {
+		t.Errorf("dropped = %d, want 1 when the records not sent exceed the maximum", stats.Dropped)
+	}
+}